@@ -2,15 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
-	"regexp"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/go-telegram/bot"
-	"github.com/go-telegram/bot/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
@@ -19,18 +24,44 @@ import (
 
 	pcl "github.com/luckyComet55/marzban-proto-contract/gen/go/contract"
 
+	"github.com/luckyComet55/marzban-tg-bot/internal/auth"
+	"github.com/luckyComet55/marzban-tg-bot/internal/flow"
 	"github.com/luckyComet55/marzban-tg-bot/internal/handler"
 	"github.com/luckyComet55/marzban-tg-bot/internal/middleware"
-	"github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/internal/preset"
 	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/internal/usermeta"
 	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/storage"
 )
 
 type AppConfig struct {
-	BotApiKey       string  `env:"BOT_TOKEN, required"`
-	AuthorizedUsers []int64 `env:"AUTHORIZED_USER_IDS, required"`
-	ServerURL       string  `env:"SERVER_URL, required"`
-	Env             string  `env:"ENV, required"`
+	BotApiKey       string              `env:"BOT_TOKEN, required"`
+	AuthorizedUsers []int64             `env:"AUTHORIZED_USER_IDS, required"`
+	DefaultRole     auth.Role           `env:"DEFAULT_ADMIN_ROLE, default=operator"`
+	AdminRoles      map[int64]auth.Role `env:"ADMIN_ROLES"`
+	ServerURL       string              `env:"SERVER_URL, required"`
+	DataDir         string              `env:"DATA_DIR, default=data"`
+	InviteTTL       time.Duration       `env:"INVITE_TTL, default=24h"`
+	RateLimit       RateLimitConfig     `env:", prefix=RATE_LIMIT_"`
+	TOTPEvents      []fsm.Event         `env:"TOTP_EVENTS, default=s,bcf"`
+	TOTPTimeout     time.Duration       `env:"TOTP_TIMEOUT, default=2m"`
+	MetricsAddr     string              `env:"METRICS_ADDR, default=:9090"`
+	Intruders       IntrudersConfig     `env:", prefix=INTRUDER_"`
+	Env             string              `env:"ENV, required"`
+}
+
+type RateLimitConfig struct {
+	ReadPerMinute   int `env:"READ_PER_MINUTE, default=60"`
+	ReadBurst       int `env:"READ_BURST, default=10"`
+	MutatePerMinute int `env:"MUTATE_PER_MINUTE, default=6"`
+	MutateBurst     int `env:"MUTATE_BURST, default=2"`
+}
+
+type IntrudersConfig struct {
+	AlertThreshold int           `env:"ALERT_THRESHOLD, default=3"`
+	Silence        time.Duration `env:"SILENCE, default=1m"`
+	MaxSilence     time.Duration `env:"MAX_SILENCE, default=24h"`
 }
 
 func main() {
@@ -53,254 +84,91 @@ func main() {
 	defer conn.Close()
 	grpcClient := pcl.NewMarzbanManagementPanelClient(conn)
 
-	adminStateMashine := fsm.NewFSM(repository.ADMIN_STATE_DEFAULT).
-		Transition(repo.ADMIN_STATE_DEFAULT, "lu", repo.ADMIN_STATE_DEFAULT).
-		Transition(repo.ADMIN_STATE_DEFAULT, "lp", repo.ADMIN_STATE_DEFAULT).
-		Transition(repo.ADMIN_STATE_DEFAULT, "cu", repo.ADMIN_STATE_CREATE_USER_INPUT_NAME).
-		Transition(repo.ADMIN_STATE_CREATE_USER_INPUT_NAME, "next", repo.ADMIN_STATE_CREATE_USER_SELECT_PROXY).
-		Transition(repo.ADMIN_STATE_CREATE_USER_SELECT_PROXY, "up", repo.ADMIN_STATE_CREATE_USER_SUBMIT_DATA).
-		Transition(repo.ADMIN_STATE_CREATE_USER_SUBMIT_DATA, "s", repo.ADMIN_STATE_DEFAULT).
-		Transition(repo.ADMIN_STATE_CREATE_USER_SUBMIT_DATA, "cnl", repo.ADMIN_STATE_DEFAULT)
-
 	userRepo := repo.NewUserRepository(grpcClient, logger.With("component", "userRepo"))
 	proxyRepo := repo.NewProxyRepository(grpcClient, logger.With("component", "proxyRepo"))
+	presetStore, err := preset.NewStore(storage.NewJSONFile[map[string]preset.Preset](filepath.Join(c.DataDir, "templates.json")))
+	if err != nil {
+		panic(err)
+	}
+	userMetaStore, err := usermeta.NewStore(storage.NewJSONFile[usermeta.File](filepath.Join(c.DataDir, "usermeta.json")))
+	if err != nil {
+		panic(err)
+	}
+	adminStateMashine := flow.NewAdminFlow(userRepo, proxyRepo, presetStore, userMetaStore, logger.With("component", "adminFlow")).Build()
 	adminRepo := repo.NewAdminRepository(adminStateMashine)
 
 	handlerWrapper := handler.NewMessageHandler(adminRepo, userRepo, proxyRepo, logger.With("component", "handlerWrapper"))
-	whitelistMidleware := middleware.NewWhitelistMiddleware(c.AuthorizedUsers, logger.With("component", "whitelistMidleware"))
-	everithingHandler := middleware.WithWhitelist(whitelistMidleware, handlerWrapper.HandleUpdate)
-	startHandler := middleware.WithWhitelist(whitelistMidleware, handlerWrapper.HandleStart)
-	cancelHandler := middleware.WithWhitelist(whitelistMidleware, handlerWrapper.HandleCancel)
-
-	adminStateMashine.OnTransition(func(from, to fsm.State, event fsm.Event, ctx *fsm.FSMContext) error {
-		logger.Debug("calling on transition")
-		b := ctx.Meta["tgbot"].(*bot.Bot)
-		u := ctx.Meta["tgchat"].(int64)
-		c := ctx.Meta["tgctx"].(context.Context)
-
-		if from == repo.ADMIN_STATE_DEFAULT && event == "lu" {
-			users, err := userRepo.GetUsers()
-			if err != nil {
-				logger.Error(err.Error())
-				if _, err := b.SendMessage(c, &bot.SendMessageParams{
-					Text:   "Unable to serve you right now, try again later",
-					ChatID: u,
-				}); err != nil {
-					logger.Error(err.Error())
-					return err
-				}
-				return err
-			}
-
-			kb := &models.InlineKeyboardMarkup{
-				InlineKeyboard: [][]models.InlineKeyboardButton{make([]models.InlineKeyboardButton, 0)},
-			}
-			for _, u := range users {
-				kb.InlineKeyboard[0] = append(kb.InlineKeyboard[0], models.InlineKeyboardButton{Text: u.Username, CallbackData: "next:"})
-			}
-
-			if _, err := b.SendMessage(c, &bot.SendMessageParams{
-				Text:        "Choose user to get all info",
-				ChatID:      u,
-				ReplyMarkup: kb,
-			}); err != nil {
-				logger.Error(err.Error())
-				return err
-			}
-			return nil
-		}
-
-		if from == repo.ADMIN_STATE_DEFAULT && event == "lp" {
-			proxies, err := proxyRepo.ListProxies()
-			if err != nil {
-				logger.Error(err.Error())
-				if _, err := b.SendMessage(c, &bot.SendMessageParams{
-					Text:   "Unable to serve you right now, try again later",
-					ChatID: u,
-				}); err != nil {
-					logger.Error(err.Error())
-					return err
-				}
-				return err
-			}
-
-			proxyMessage := fmt.Sprintf("Total of %d proxies:\n", len(proxies))
-
-			for _, p := range proxies {
-				proxyMessage += fmt.Sprintf("- %s\n", p.ProxyName)
-			}
-
-			if _, err := b.SendMessage(c, &bot.SendMessageParams{
-				Text:   proxyMessage,
-				ChatID: u,
-			}); err != nil {
-				logger.Error(err.Error())
-				return err
-			}
-			return nil
-		}
-
-		return nil
-	})
-
-	adminStateMashine.OnEnter(repo.ADMIN_STATE_CREATE_USER_INPUT_NAME, func(ctx *fsm.FSMContext) error {
-		b := ctx.Meta["tgbot"].(*bot.Bot)
-		u := ctx.Meta["tgchat"].(int64)
-		c := ctx.Meta["tgctx"].(context.Context)
-
-		b.SendMessage(c, &bot.SendMessageParams{
-			Text:   "Input username. It must be 3-32 symbols [a-zA-Z0-9_]",
-			ChatID: u,
-		})
-
-		return nil
-	})
-
-	adminStateMashine.OnExit(repo.ADMIN_STATE_CREATE_USER_INPUT_NAME, func(ctx *fsm.FSMContext) error {
-		userName := ctx.Input.(string)
-
-		matchString := "^[a-zA-Z0-9_]{3,32}$"
-
-		isValid, err := regexp.MatchString(matchString, userName)
-		if err != nil {
-			logger.Error(err.Error())
-			return err
-		}
-
-		if !isValid {
-			return fmt.Errorf("username '%s' does not match pattern %s", userName, matchString)
-		}
-
-		ctx.Data["username"] = userName
-		return nil
-	})
-
-	adminStateMashine.OnEnter(repo.ADMIN_STATE_CREATE_USER_SELECT_PROXY, func(ctx *fsm.FSMContext) error {
-		b := ctx.Meta["tgbot"].(*bot.Bot)
-		u := ctx.Meta["tgchat"].(int64)
-		c := ctx.Meta["tgctx"].(context.Context)
-
-		proxies, err := proxyRepo.ListProxies()
-		if err != nil {
-			logger.Error(err.Error())
-			return err
-		}
-
-		kb := &models.InlineKeyboardMarkup{
-			InlineKeyboard: [][]models.InlineKeyboardButton{make([]models.InlineKeyboardButton, 0)},
-		}
-
-		for _, p := range proxies {
-			kb.InlineKeyboard[0] = append(kb.InlineKeyboard[0], models.InlineKeyboardButton{Text: p.ProxyName, CallbackData: fmt.Sprintf("up:%s", p.ProxyName)})
-		}
-
-		b.SendMessage(c, &bot.SendMessageParams{
-			ChatID:      u,
-			Text:        "Select user proxy configuration from list",
-			ReplyMarkup: kb,
-		})
-
-		return nil
-	})
-
-	adminStateMashine.OnExit(repo.ADMIN_STATE_CREATE_USER_SELECT_PROXY, func(ctx *fsm.FSMContext) error {
-		ctx.Data["proxy"] = ctx.Input.(string)
-		return nil
-	})
-
-	adminStateMashine.OnEnter(repo.ADMIN_STATE_CREATE_USER_SUBMIT_DATA, func(ctx *fsm.FSMContext) error {
-		b := ctx.Meta["tgbot"].(*bot.Bot)
-		u := ctx.Meta["tgchat"].(int64)
-		c := ctx.Meta["tgctx"].(context.Context)
-
-		username := ctx.Data["username"].(string)
-		proxy := ctx.Data["proxy"].(string)
-
-		kb := &models.InlineKeyboardMarkup{
-			InlineKeyboard: [][]models.InlineKeyboardButton{
-				{
-					{Text: "Submit", CallbackData: "s:"},
-				},
-				{
-					{Text: "Cancel", CallbackData: "cnl:"},
-				},
-			},
-		}
-
-		b.SendMessage(c, &bot.SendMessageParams{
-			Text:        fmt.Sprintf("Username: %s\nProxy config: %s", username, proxy),
-			ChatID:      u,
-			ReplyMarkup: kb,
-		})
-
-		return nil
-	})
-
-	adminStateMashine.OnTransition(func(from, to fsm.State, event fsm.Event, ctx *fsm.FSMContext) error {
-		if event != "s" {
-			return nil
-		}
-
-		b := ctx.Meta["tgbot"].(*bot.Bot)
-		u := ctx.Meta["tgchat"].(int64)
-		c := ctx.Meta["tgctx"].(context.Context)
-
-		username := ctx.Data["username"].(string)
-		proxy := ctx.Data["proxy"].(string)
-
-		userCreateData := repo.UserCreateData{
-			Username:      username,
-			ProxyProtocol: proxy,
-		}
-
-		userData, err := userRepo.CreateUser(userCreateData)
-		if err != nil {
-			b.SendMessage(c, &bot.SendMessageParams{
-				ChatID: u,
-				Text:   "Could not add user, try again later",
-			})
-			return err
-		}
-
-		userFormat := "Created user:\nusername: %s\nproxy config: %s\nconfig url: `%s`"
-		b.SendMessage(c, &bot.SendMessageParams{
-			ChatID:    u,
-			Text:      fmt.Sprintf(userFormat, userData.Username, userData.ProxyProtocol, userData.ConfigUrl),
-			ParseMode: models.ParseModeMarkdownV1,
-		})
-		return nil
-	})
-
-	adminStateMashine.OnEnter(repo.ADMIN_STATE_DEFAULT, func(ctx *fsm.FSMContext) error {
-		b := ctx.Meta["tgbot"].(*bot.Bot)
-		u := ctx.Meta["tgchat"].(int64)
-		c := ctx.Meta["tgctx"].(context.Context)
-
-		kb := &models.InlineKeyboardMarkup{
-			InlineKeyboard: [][]models.InlineKeyboardButton{
-				{
-					{Text: "List users", CallbackData: "lu:"},
-					{Text: "List proxies", CallbackData: "lp:"},
-				},
-				{
-					{Text: "Create user", CallbackData: "cu:"},
-				},
-			},
-		}
-
-		b.SendMessage(c, &bot.SendMessageParams{
-			ChatID:      u,
-			Text:        "Select action",
-			ReplyMarkup: kb,
-		})
-		return nil
-	})
+	whitelistStore := storage.NewJSONFile[map[int64]auth.Role](filepath.Join(c.DataDir, "admins.json"))
+	intruderTracker := middleware.NewIntruderTracker(c.Intruders.AlertThreshold, c.Intruders.Silence, c.Intruders.MaxSilence)
+	whitelistMidleware, err := middleware.NewWhitelistMiddleware(userRoles(c), whitelistStore, intruderTracker, logger.With("component", "whitelistMidleware"))
+	if err != nil {
+		panic(err)
+	}
+	inviteStore, err := auth.NewInviteStore(storage.NewJSONFile[map[string]auth.Invite](filepath.Join(c.DataDir, "invites.json")), c.InviteTTL)
+	if err != nil {
+		panic(err)
+	}
+	whitelistHandler := handler.NewWhitelistHandler(whitelistMidleware, inviteStore, intruderTracker, adminRepo, logger.With("component", "whitelistHandler"))
+	rateLimitMiddleware := middleware.NewRateLimitMiddleware(map[middleware.ActionClass]middleware.RateLimit{
+		middleware.ACTION_CLASS_READ:   {PerMinute: c.RateLimit.ReadPerMinute, Burst: c.RateLimit.ReadBurst},
+		middleware.ACTION_CLASS_MUTATE: {PerMinute: c.RateLimit.MutatePerMinute, Burst: c.RateLimit.MutateBurst},
+	}, logger.With("component", "rateLimitMiddleware"))
+	totpStore, err := auth.NewTOTPStore(storage.NewJSONFile[map[int64]auth.TOTPSecret](filepath.Join(c.DataDir, "totp.json")))
+	if err != nil {
+		panic(err)
+	}
+	totpMiddleware := middleware.NewTOTPMiddleware(totpStore, c.TOTPEvents, c.TOTPTimeout, logger.With("component", "totpMiddleware"))
+	totpHandler := handler.NewTOTPHandler(totpStore, logger.With("component", "totpHandler"))
+	templateHandler := handler.NewTemplateHandler(presetStore, userRepo, proxyRepo, logger.With("component", "templateHandler"))
+	userMetaHandler := handler.NewUserMetaHandler(userMetaStore, logger.With("component", "userMetaHandler"))
+	baseChain := middleware.NewChain(
+		middleware.RequestID,
+		middleware.Recovery(logger.With("component", "recovery")),
+		middleware.Logging(logger.With("component", "updates")),
+	)
+	metricsRegistry := prometheus.NewRegistry()
+	metricsMiddleware := middleware.NewMetricsMiddleware(metricsRegistry)
+	accessMiddlewares := []middleware.Middleware{
+		whitelistMidleware.Wrap,
+		rateLimitMiddleware.Wrap,
+		metricsMiddleware.Wrap,
+		totpMiddleware.Wrap,
+	}
+	protectedChain := baseChain.Append(accessMiddlewares...)
+
+	everithingHandler := protectedChain.Then(handlerWrapper.HandleUpdate)
+	startHandler := baseChain.
+		Append(middleware.Invite(whitelistMidleware, inviteStore)).
+		Append(accessMiddlewares...).
+		Then(handlerWrapper.HandleStart)
+
+	commands := []struct {
+		command fsm.Event
+		handler bot.HandlerFunc
+	}{
+		{repo.ADMIN_COMMAND_CANCEL, handlerWrapper.HandleCancel},
+		{repo.ADMIN_COMMAND_ADMINS, whitelistHandler.HandleAdmins},
+		{repo.ADMIN_COMMAND_ADMIN_ADD, whitelistHandler.HandleAdminAdd},
+		{repo.ADMIN_COMMAND_ADMIN_REMOVE, whitelistHandler.HandleAdminRemove},
+		{repo.ADMIN_COMMAND_INVITE, whitelistHandler.HandleInvite},
+		{repo.ADMIN_COMMAND_INTRUDERS, whitelistHandler.HandleIntruders},
+		{repo.ADMIN_COMMAND_TOTP_ENROLL, totpHandler.HandleEnroll},
+		{repo.ADMIN_COMMAND_TOTP_CONFIRM, totpHandler.HandleConfirm},
+		{repo.ADMIN_COMMAND_TOTP_DISABLE, totpHandler.HandleDisable},
+		{repo.ADMIN_COMMAND_EXPORT, handlerWrapper.HandleExport},
+		{repo.ADMIN_COMMAND_TEMPLATES, templateHandler.HandleTemplates},
+		{repo.ADMIN_COMMAND_META_RENAME, userMetaHandler.HandleRename},
+	}
 
 	opts := []bot.Option{
 		bot.WithDefaultHandler(everithingHandler),
 		bot.WithDebug(),
-		bot.WithMessageTextHandler("/start", bot.MatchTypeExact, startHandler),
-		bot.WithMessageTextHandler("/cancel", bot.MatchTypeExact, cancelHandler),
+		bot.WithMessageTextHandler(strings.TrimPrefix(string(repo.ADMIN_COMMAND_START), "/"), bot.MatchTypeCommandStartOnly, startHandler),
+	}
+	// Commands are matched as a whole word, so that /admin_addX or
+	// /templatesfoo do not reach the handlers of /admin_add and /templates.
+	for _, cmd := range commands {
+		opts = append(opts, bot.WithMessageTextHandler(strings.TrimPrefix(string(cmd.command), "/"), bot.MatchTypeCommandStartOnly, protectedChain.Then(cmd.handler)))
 	}
 
 	b, err := bot.New(c.BotApiKey, opts...)
@@ -308,9 +176,43 @@ func main() {
 		panic(err)
 	}
 
+	totpHandler.ScheduleEnrollMessages(ctx, b)
+
+	if c.MetricsAddr != "" {
+		go serveMetrics(ctx, c.MetricsAddr, metricsRegistry, logger.With("component", "metrics"))
+	}
+
 	b.Start(ctx)
 }
 
+// serveMetrics exposes the registry for Prometheus until ctx is done.
+func serveMetrics(ctx context.Context, addr string, registry *prometheus.Registry, logger *slog.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	logger.Info(fmt.Sprintf("serving metrics on %s", addr))
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error(err.Error())
+	}
+}
+
+func userRoles(c AppConfig) map[int64]auth.Role {
+	roles := make(map[int64]auth.Role, len(c.AuthorizedUsers)+len(c.AdminRoles))
+	for _, userID := range c.AuthorizedUsers {
+		roles[userID] = c.DefaultRole
+	}
+	for userID, role := range c.AdminRoles {
+		roles[userID] = role
+	}
+	return roles
+}
+
 func configureLogger(c AppConfig) *slog.Logger {
 	var logger *slog.Logger
 	switch c.Env {
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/luckyComet55/marzban-tg-bot/pkg/storage"
+)
+
+var ErrInviteInvalid = errors.New("invite is invalid or expired")
+
+type Invite struct {
+	Token     string    `json:"token"`
+	Role      Role      `json:"role"`
+	CreatedBy int64     `json:"created_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// InviteStore keeps single-use invite tokens. Redeemed and
+// expired invites are dropped from the store.
+type InviteStore struct {
+	store   *storage.JSONFile[map[string]Invite]
+	invites map[string]Invite
+	ttl     time.Duration
+	mu      sync.Mutex
+}
+
+func NewInviteStore(store *storage.JSONFile[map[string]Invite], ttl time.Duration) (*InviteStore, error) {
+	invites, _, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if invites == nil {
+		invites = make(map[string]Invite)
+	}
+
+	return &InviteStore{
+		store:   store,
+		invites: invites,
+		ttl:     ttl,
+	}, nil
+}
+
+func (is *InviteStore) TTL() time.Duration {
+	return is.ttl
+}
+
+func (is *InviteStore) Create(role Role, createdBy int64) (Invite, error) {
+	if role == ROLE_NONE {
+		return Invite{}, fmt.Errorf("role %s can not be assigned", role)
+	}
+
+	raw := make([]byte, 18)
+	if _, err := rand.Read(raw); err != nil {
+		return Invite{}, err
+	}
+
+	invite := Invite{
+		Token:     base64.RawURLEncoding.EncodeToString(raw),
+		Role:      role,
+		CreatedBy: createdBy,
+		ExpiresAt: time.Now().Add(is.ttl),
+	}
+
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	updated := is.withoutExpired()
+	updated[invite.Token] = invite
+	if err := is.store.Save(updated); err != nil {
+		return Invite{}, err
+	}
+	is.invites = updated
+	return invite, nil
+}
+
+// Redeem consumes the invite, so every token can be used only once.
+func (is *InviteStore) Redeem(token string) (Invite, error) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	updated := is.withoutExpired()
+	invite, ok := updated[token]
+	if !ok {
+		return Invite{}, ErrInviteInvalid
+	}
+
+	delete(updated, token)
+	if err := is.store.Save(updated); err != nil {
+		return Invite{}, err
+	}
+	is.invites = updated
+	return invite, nil
+}
+
+func (is *InviteStore) withoutExpired() map[string]Invite {
+	now := time.Now()
+	invites := maps.Clone(is.invites)
+	maps.DeleteFunc(invites, func(_ string, invite Invite) bool {
+		return now.After(invite.ExpiresAt)
+	})
+	return invites
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+)
+
+type Role int
+
+const (
+	ROLE_NONE Role = iota
+	ROLE_VIEWER
+	ROLE_OPERATOR
+	ROLE_OWNER
+)
+
+var roleNames = map[Role]string{
+	ROLE_NONE:     "none",
+	ROLE_VIEWER:   "viewer",
+	ROLE_OPERATOR: "operator",
+	ROLE_OWNER:    "owner",
+}
+
+// eventRoles holds the minimal role required to trigger an event.
+// Events missing from the table are allowed for owners only.
+var eventRoles = map[fsm.Event]Role{
+	repo.ADMIN_EVENT_NEXT:          ROLE_VIEWER,
+	repo.ADMIN_EVENT_CANCEL:        ROLE_VIEWER,
+	repo.ADMIN_EVENT_LIST_USERS:    ROLE_VIEWER,
+	repo.ADMIN_EVENT_LIST_PROXIES:  ROLE_VIEWER,
+	repo.ADMIN_EVENT_USER_DETAILS:  ROLE_VIEWER,
+	repo.ADMIN_EVENT_BACK:          ROLE_VIEWER,
+	repo.ADMIN_EVENT_PAGE:          ROLE_VIEWER,
+	repo.ADMIN_EVENT_SORT:          ROLE_VIEWER,
+	repo.ADMIN_EVENT_FIND_USER:     ROLE_VIEWER,
+	repo.ADMIN_EVENT_QR_CODES:      ROLE_VIEWER,
+	repo.ADMIN_EVENT_CLIENT_CONFIG: ROLE_VIEWER,
+	repo.ADMIN_EVENT_EXPORT_FORMAT: ROLE_VIEWER,
+	repo.ADMIN_EVENT_EXPORT_COLUMN: ROLE_VIEWER,
+	repo.ADMIN_EVENT_EXPORT:        ROLE_VIEWER,
+	repo.ADMIN_EVENT_CREATE_USER:   ROLE_OPERATOR,
+	repo.ADMIN_EVENT_USE_TEMPLATE:  ROLE_OPERATOR,
+	repo.ADMIN_EVENT_USE_PROXY:     ROLE_OPERATOR,
+	repo.ADMIN_EVENT_SUBMIT_CREATE: ROLE_OPERATOR,
+	repo.ADMIN_EVENT_CONFIRM_BULK:  ROLE_OPERATOR,
+	repo.ADMIN_EVENT_BULK_CREATE:   ROLE_OPERATOR,
+	repo.ADMIN_EVENT_USER_META:     ROLE_OPERATOR,
+	repo.ADMIN_EVENT_SKIP:          ROLE_OPERATOR,
+
+	repo.ADMIN_COMMAND_START:        ROLE_VIEWER,
+	repo.ADMIN_COMMAND_CANCEL:       ROLE_VIEWER,
+	repo.ADMIN_COMMAND_ADMINS:       ROLE_OWNER,
+	repo.ADMIN_COMMAND_ADMIN_ADD:    ROLE_OWNER,
+	repo.ADMIN_COMMAND_ADMIN_REMOVE: ROLE_OWNER,
+	repo.ADMIN_COMMAND_INVITE:       ROLE_OWNER,
+	repo.ADMIN_COMMAND_INTRUDERS:    ROLE_OWNER,
+	repo.ADMIN_COMMAND_TOTP_ENROLL:  ROLE_VIEWER,
+	repo.ADMIN_COMMAND_TOTP_CONFIRM: ROLE_VIEWER,
+	repo.ADMIN_COMMAND_TOTP_DISABLE: ROLE_VIEWER,
+	repo.ADMIN_COMMAND_EXPORT:       ROLE_VIEWER,
+	repo.ADMIN_COMMAND_TEMPLATES:    ROLE_OPERATOR,
+	repo.ADMIN_COMMAND_META_RENAME:  ROLE_OPERATOR,
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("role(%d)", int(r))
+}
+
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText lets roles be decoded from env config and JSON files.
+func (r *Role) UnmarshalText(text []byte) error {
+	role, err := ParseRole(string(text))
+	if err != nil {
+		return err
+	}
+	*r = role
+	return nil
+}
+
+func ParseRole(name string) (Role, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for role, roleName := range roleNames {
+		if role != ROLE_NONE && roleName == name {
+			return role, nil
+		}
+	}
+	return ROLE_NONE, fmt.Errorf("unknown role '%s'. possible values: viewer, operator, owner", name)
+}
+
+func Can(role Role, event fsm.Event) bool {
+	required, ok := eventRoles[event]
+	if !ok {
+		required = ROLE_OWNER
+	}
+	return role != ROLE_NONE && role >= required
+}
+
+// Guard builds an FSM guard that lets the transition through only
+// for admins whose role, stored in the "tgrole" meta, permits the event.
+func Guard(event fsm.Event) fsm.GuardFunc {
+	return func(ctx *fsm.FSMContext) bool {
+		role, _ := ctx.Meta["tgrole"].(Role)
+		return Can(role, event)
+	}
+}
+
+type roleKey struct{}
+
+func WithRole(ctx context.Context, role Role) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+func RoleFromContext(ctx context.Context) Role {
+	role, _ := ctx.Value(roleKey{}).(Role)
+	return role
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+)
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		role  Role
+		event fsm.Event
+		can   bool
+	}{
+		{ROLE_VIEWER, repo.ADMIN_EVENT_LIST_USERS, true},
+		{ROLE_VIEWER, repo.ADMIN_EVENT_USER_DETAILS, true},
+		{ROLE_VIEWER, repo.ADMIN_EVENT_CREATE_USER, false},
+		{ROLE_VIEWER, repo.ADMIN_EVENT_SUBMIT_CREATE, false},
+		{ROLE_OPERATOR, repo.ADMIN_EVENT_SUBMIT_CREATE, true},
+		{ROLE_OPERATOR, repo.ADMIN_EVENT_CONFIRM_BULK, true},
+		{ROLE_OPERATOR, repo.ADMIN_COMMAND_ADMIN_ADD, false},
+		{ROLE_OWNER, repo.ADMIN_COMMAND_ADMIN_ADD, true},
+		{ROLE_OWNER, repo.ADMIN_EVENT_LIST_USERS, true},
+		{ROLE_OPERATOR, fsm.Event("unknown"), false},
+		{ROLE_OWNER, fsm.Event("unknown"), true},
+		{ROLE_NONE, repo.ADMIN_EVENT_LIST_USERS, false},
+		{ROLE_NONE, repo.ADMIN_COMMAND_START, false},
+	}
+	for _, tt := range tests {
+		if got := Can(tt.role, tt.event); got != tt.can {
+			t.Errorf("Can(%s, %s) = %t, want %t", tt.role, tt.event, got, tt.can)
+		}
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	tests := []struct {
+		name  string
+		role  Role
+		valid bool
+	}{
+		{"viewer", ROLE_VIEWER, true},
+		{"Operator", ROLE_OPERATOR, true},
+		{" owner ", ROLE_OWNER, true},
+		{"none", ROLE_NONE, false},
+		{"admin", ROLE_NONE, false},
+		{"", ROLE_NONE, false},
+	}
+	for _, tt := range tests {
+		role, err := ParseRole(tt.name)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseRole(%q) error = %v, want valid %t", tt.name, err, tt.valid)
+		}
+		if role != tt.role {
+			t.Errorf("ParseRole(%q) = %s, want %s", tt.name, role, tt.role)
+		}
+	}
+}
+
+func TestRoleText(t *testing.T) {
+	for _, role := range []Role{ROLE_VIEWER, ROLE_OPERATOR, ROLE_OWNER} {
+		text, err := role.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Role
+		if err := decoded.UnmarshalText(text); err != nil {
+			t.Fatalf("UnmarshalText(%q): %v", text, err)
+		}
+		if decoded != role {
+			t.Errorf("role %s is decoded as %s", role, decoded)
+		}
+	}
+	if got := Role(42).String(); got != "role(42)" {
+		t.Errorf("unknown role String() = %s", got)
+	}
+}
+
+func TestGuard(t *testing.T) {
+	tests := []struct {
+		name  string
+		meta  map[string]any
+		event fsm.Event
+		allow bool
+	}{
+		{name: "permitted", meta: map[string]any{"tgrole": ROLE_OPERATOR}, event: repo.ADMIN_EVENT_CREATE_USER, allow: true},
+		{name: "not permitted", meta: map[string]any{"tgrole": ROLE_VIEWER}, event: repo.ADMIN_EVENT_CREATE_USER},
+		{name: "no role", meta: map[string]any{}, event: repo.ADMIN_EVENT_LIST_USERS},
+		{name: "wrong type", meta: map[string]any{"tgrole": "owner"}, event: repo.ADMIN_EVENT_LIST_USERS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &fsm.FSMContext{Meta: tt.meta}
+			if got := Guard(tt.event)(ctx); got != tt.allow {
+				t.Errorf("guard = %t, want %t", got, tt.allow)
+			}
+		})
+	}
+}
+
+func TestRoleContext(t *testing.T) {
+	if role := RoleFromContext(context.Background()); role != ROLE_NONE {
+		t.Errorf("role of an empty context = %s, want %s", role, ROLE_NONE)
+	}
+	if role := RoleFromContext(WithRole(context.Background(), ROLE_OPERATOR)); role != ROLE_OPERATOR {
+		t.Errorf("role = %s, want %s", role, ROLE_OPERATOR)
+	}
+}
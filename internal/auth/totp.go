@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"errors"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/luckyComet55/marzban-tg-bot/pkg/storage"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/totp"
+)
+
+var (
+	ErrTOTPNotEnrolled = errors.New("TOTP is not enrolled")
+	ErrTOTPInvalidCode = errors.New("TOTP code is invalid or already used")
+)
+
+// TOTPSecret is confirmed once the admin proves that the secret
+// was imported into an authenticator app. LastStep keeps the period
+// of the last accepted code, so a code can not be used twice.
+// EnrollMessage is the message with the QR code of the secret,
+// kept until the bot deletes it.
+type TOTPSecret struct {
+	Secret        string         `json:"secret"`
+	Confirmed     bool           `json:"confirmed"`
+	LastStep      int64          `json:"last_step"`
+	EnrollMessage *EnrollMessage `json:"enroll_message,omitempty"`
+}
+
+type EnrollMessage struct {
+	ChatID    int64     `json:"chat_id"`
+	MessageID int       `json:"message_id"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+type TOTPStore struct {
+	store   *storage.JSONFile[map[int64]TOTPSecret]
+	secrets map[int64]TOTPSecret
+	mu      sync.Mutex
+}
+
+func NewTOTPStore(store *storage.JSONFile[map[int64]TOTPSecret]) (*TOTPStore, error) {
+	secrets, _, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if secrets == nil {
+		secrets = make(map[int64]TOTPSecret)
+	}
+
+	return &TOTPStore{
+		store:   store,
+		secrets: secrets,
+	}, nil
+}
+
+func (ts *TOTPStore) IsEnrolled(adminID int64) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	return ts.secrets[adminID].Confirmed
+}
+
+// Enroll generates a new unconfirmed secret for the admin,
+// replacing any previous unconfirmed one.
+func (ts *TOTPStore) Enroll(adminID int64) (string, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.secrets[adminID].Confirmed {
+		return "", errors.New("TOTP is already enrolled")
+	}
+
+	if err := ts.save(adminID, TOTPSecret{Secret: secret}); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Verify checks the code against the admin's secret. A valid code
+// for an unconfirmed secret confirms it.
+func (ts *TOTPStore) Verify(adminID int64, code string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	secret, ok := ts.secrets[adminID]
+	if !ok {
+		return ErrTOTPNotEnrolled
+	}
+
+	step, ok := totp.Validate(secret.Secret, code, time.Now())
+	if !ok || step <= secret.LastStep {
+		return ErrTOTPInvalidCode
+	}
+
+	secret.Confirmed = true
+	secret.LastStep = step
+	return ts.save(adminID, secret)
+}
+
+// SetEnrollMessage remembers the QR code message of the admin's secret,
+// so it can be deleted after a restart too.
+func (ts *TOTPStore) SetEnrollMessage(adminID int64, message EnrollMessage) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	secret, ok := ts.secrets[adminID]
+	if !ok {
+		return ErrTOTPNotEnrolled
+	}
+	secret.EnrollMessage = &message
+	return ts.save(adminID, secret)
+}
+
+// TakeEnrollMessage forgets the QR code message of the admin's secret
+// and returns it. The flag is false when there is no such message.
+func (ts *TOTPStore) TakeEnrollMessage(adminID int64) (EnrollMessage, bool, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	secret, ok := ts.secrets[adminID]
+	if !ok || secret.EnrollMessage == nil {
+		return EnrollMessage{}, false, nil
+	}
+	message := *secret.EnrollMessage
+	secret.EnrollMessage = nil
+	if err := ts.save(adminID, secret); err != nil {
+		return EnrollMessage{}, false, err
+	}
+	return message, true, nil
+}
+
+// EnrollMessages returns the QR code messages that are not deleted yet.
+func (ts *TOTPStore) EnrollMessages() map[int64]EnrollMessage {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	messages := make(map[int64]EnrollMessage)
+	for adminID, secret := range ts.secrets {
+		if secret.EnrollMessage != nil {
+			messages[adminID] = *secret.EnrollMessage
+		}
+	}
+	return messages
+}
+
+func (ts *TOTPStore) Remove(adminID int64) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	updated := maps.Clone(ts.secrets)
+	delete(updated, adminID)
+	if err := ts.store.Save(updated); err != nil {
+		return err
+	}
+	ts.secrets = updated
+	return nil
+}
+
+func (ts *TOTPStore) save(adminID int64, secret TOTPSecret) error {
+	updated := maps.Clone(ts.secrets)
+	updated[adminID] = secret
+	if err := ts.store.Save(updated); err != nil {
+		return err
+	}
+	ts.secrets = updated
+	return nil
+}
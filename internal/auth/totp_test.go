@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luckyComet55/marzban-tg-bot/pkg/storage"
+)
+
+func TestEnrollMessageSurvivesRestart(t *testing.T) {
+	file := storage.NewJSONFile[map[int64]TOTPSecret](filepath.Join(t.TempDir(), "totp.json"))
+	store, err := NewTOTPStore(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.SetEnrollMessage(1, EnrollMessage{ChatID: 1, MessageID: 10}); err != ErrTOTPNotEnrolled {
+		t.Errorf("SetEnrollMessage without a secret = %v, want %v", err, ErrTOTPNotEnrolled)
+	}
+	if _, err := store.Enroll(1); err != nil {
+		t.Fatal(err)
+	}
+	message := EnrollMessage{ChatID: 1, MessageID: 10, SentAt: time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)}
+	if err := store.SetEnrollMessage(1, message); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err := NewTOTPStore(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := restarted.EnrollMessages(); len(got) != 1 || !got[1].SentAt.Equal(message.SentAt) || got[1].MessageID != message.MessageID {
+		t.Errorf("EnrollMessages after restart = %+v, want %+v", got, message)
+	}
+
+	taken, ok, err := restarted.TakeEnrollMessage(1)
+	if err != nil || !ok || taken.MessageID != message.MessageID {
+		t.Errorf("TakeEnrollMessage = %+v, %t, %v, want %+v", taken, ok, err, message)
+	}
+	if _, ok, _ := restarted.TakeEnrollMessage(1); ok {
+		t.Error("message is taken twice")
+	}
+	if got := restarted.EnrollMessages(); len(got) != 0 {
+		t.Errorf("EnrollMessages after take = %+v, want none", got)
+	}
+}
@@ -0,0 +1,57 @@
+package bulk
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"sync"
+
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+)
+
+// Result is the outcome of creating one user.
+type Result struct {
+	Username   string
+	ConfigUrls []string
+	Err        error
+}
+
+// Create creates users through the repository with at most
+// concurrency requests at a time. Results keep the order of users.
+func Create(userRepository repo.UserRepository, users []repo.UserCreateData, concurrency int) []Result {
+	results := make([]Result, len(users))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, user := range users {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			userData, err := userRepository.CreateUser(user)
+			results[i] = Result{Username: user.Username, ConfigUrls: userData.ConfigUrls, Err: err}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// WriteResults writes results as CSV with config URLs separated by spaces.
+func WriteResults(w io.Writer, results []Result) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"username", "status", "config_urls", "error"}); err != nil {
+		return err
+	}
+	for _, result := range results {
+		record := []string{result.Username, "created", strings.Join(result.ConfigUrls, " "), ""}
+		if result.Err != nil {
+			record[1] = "failed"
+			record[3] = result.Err.Error()
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
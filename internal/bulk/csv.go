@@ -0,0 +1,152 @@
+// Package bulk creates many users at once from a CSV file.
+package bulk
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/internal/validation"
+)
+
+const MaxRows = 1000
+
+// Columns are the CSV header every upload must start with.
+var Columns = []string{"username", "proxies", "data_limit", "expire", "note"}
+
+var ErrInvalidHeader = fmt.Errorf("CSV header must be %s", strings.Join(Columns, ","))
+
+// Row is a parsed CSV line. Rows with Err set are skipped on creation.
+type Row struct {
+	Line int
+	User repo.UserCreateData
+	Err  error
+}
+
+// ParseUsers reads users from CSV and validates every row with the same
+// rules as the create user wizard. Proxies are separated by ';' and must
+// be among the known ones.
+func ParseUsers(r io.Reader, knownProxies []string, now time.Time) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(Columns)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")))
+	}
+	if !slices.Equal(header, Columns) {
+		return nil, ErrInvalidHeader
+	}
+
+	rows := make([]Row, 0)
+	seen := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("reading CSV: %w", err)
+			}
+			rows = append(rows, Row{Line: parseErr.Line, Err: parseErr.Err})
+			continue
+		}
+		if len(rows) == MaxRows {
+			return nil, fmt.Errorf("CSV has more than %d rows", MaxRows)
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := parseRow(line, record, knownProxies, now)
+		if row.Err == nil {
+			if first, ok := seen[row.User.Username]; ok {
+				row.Err = fmt.Errorf("username %s is repeated from line %d", row.User.Username, first)
+			} else {
+				seen[row.User.Username] = line
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseRow(line int, record []string, knownProxies []string, now time.Time) Row {
+	row := Row{Line: line}
+	username := strings.TrimSpace(record[0])
+	row.User.Username = username
+
+	if err := validation.Username(username); err != nil {
+		row.Err = err
+		return row
+	}
+
+	for _, proxy := range strings.Split(record[1], ";") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !slices.Contains(knownProxies, proxy) {
+			row.Err = fmt.Errorf("unknown proxy '%s'", proxy)
+			return row
+		}
+		if !slices.Contains(row.User.ProxyNames, proxy) {
+			row.User.ProxyNames = append(row.User.ProxyNames, proxy)
+		}
+	}
+	if len(row.User.ProxyNames) == 0 {
+		row.Err = errors.New("no proxies given")
+		return row
+	}
+
+	limit := strings.TrimSpace(record[2])
+	if limit == "" {
+		limit = "unlimited"
+	}
+	if row.User.DataLimit, row.Err = validation.DataLimit(limit); row.Err != nil {
+		return row
+	}
+	if row.User.ExpireAt, row.Err = validation.Expiry(record[3], now); row.Err != nil {
+		return row
+	}
+
+	row.User.Note = strings.TrimSpace(record[4])
+	row.Err = validation.Note(row.User.Note)
+	return row
+}
+
+// RejectUnsupported marks valid rows that use settings the user repository
+// can not apply on creation, so that they show up as invalid in the preview.
+func RejectUnsupported(rows []Row, userRepository repo.UserRepository) {
+	for i, row := range rows {
+		if row.Err != nil {
+			continue
+		}
+		user := row.User
+		if (user.DataLimit != 0 || !user.ExpireAt.IsZero() || user.Note != "") && !userRepository.Supports(repo.USER_OPERATION_CREATE_LIMITS) {
+			rows[i].Err = errors.New("data limit, expire and note must be empty, the panel does not support them on creation yet")
+		} else if len(user.ProxyNames) > 1 && !userRepository.Supports(repo.USER_OPERATION_CREATE_MULTI_PROXY) {
+			rows[i].Err = errors.New("the panel supports a single proxy per user on creation")
+		}
+	}
+}
+
+// Valid returns the users of rows without errors.
+func Valid(rows []Row) []repo.UserCreateData {
+	users := make([]repo.UserCreateData, 0, len(rows))
+	for _, row := range rows {
+		if row.Err == nil {
+			users = append(users, row.User)
+		}
+	}
+	return users
+}
@@ -0,0 +1,228 @@
+package bulk
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+)
+
+// limitedRepository supports only the listed operations, the way
+// a panel with an older contract does.
+type limitedRepository struct {
+	repo.UserRepository
+	supported []repo.UserOperation
+}
+
+func (r limitedRepository) Supports(operation repo.UserOperation) bool {
+	return slices.Contains(r.supported, operation)
+}
+
+var testNow = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+func TestParseUsersHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		valid bool
+	}{
+		{name: "exact", input: "username,proxies,data_limit,expire,note\n", valid: true},
+		{name: "case and BOM", input: "\ufeffUsername, Proxies,DATA_LIMIT,expire,note\n", valid: true},
+		{name: "reordered", input: "proxies,username,data_limit,expire,note\n"},
+		{name: "missing column", input: "username,proxies,data_limit,expire\n"},
+		{name: "empty", input: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseUsers(strings.NewReader(tt.input), []string{"vless"}, testNow)
+			if (err == nil) != tt.valid {
+				t.Errorf("error = %v, want valid %t", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestParseUsersRows(t *testing.T) {
+	tests := []struct {
+		name  string
+		row   string
+		user  repo.UserCreateData
+		valid bool
+	}{
+		{
+			name:  "minimal",
+			row:   "alice,vless,,,",
+			user:  repo.UserCreateData{Username: "alice", ProxyNames: []string{"vless"}},
+			valid: true,
+		},
+		{
+			name: "full",
+			row:  `bob,vless;vmess;vless,10GB,30d,"paid, VIP"`,
+			user: repo.UserCreateData{
+				Username:   "bob",
+				ProxyNames: []string{"vless", "vmess"},
+				DataLimit:  10 << 30,
+				ExpireAt:   testNow.AddDate(0, 0, 30),
+				Note:       "paid, VIP",
+			},
+			valid: true,
+		},
+		{name: "bad username", row: "a-b,vless,,,"},
+		{name: "unknown proxy", row: "alice,trojan,,,"},
+		{name: "no proxies", row: "alice, ; ,,,"},
+		{name: "bad limit", row: "alice,vless,lots,,"},
+		{name: "past expiry", row: "alice,vless,,2024-01-01,"},
+		{name: "long note", row: "alice,vless,,," + strings.Repeat("a", 501)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.Join(Columns, ",") + "\n" + tt.row + "\n"
+			rows, err := ParseUsers(strings.NewReader(input), []string{"vless", "vmess"}, testNow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 1 {
+				t.Fatalf("got %d rows, want 1", len(rows))
+			}
+			row := rows[0]
+			if (row.Err == nil) != tt.valid {
+				t.Fatalf("row error = %v, want valid %t", row.Err, tt.valid)
+			}
+			if row.Line != 2 {
+				t.Errorf("row line = %d, want 2", row.Line)
+			}
+			if !tt.valid {
+				return
+			}
+			if row.User.Username != tt.user.Username || !slices.Equal(row.User.ProxyNames, tt.user.ProxyNames) ||
+				row.User.DataLimit != tt.user.DataLimit || !row.User.ExpireAt.Equal(tt.user.ExpireAt) || row.User.Note != tt.user.Note {
+				t.Errorf("row user = %+v, want %+v", row.User, tt.user)
+			}
+		})
+	}
+}
+
+func TestParseUsersRepeatedAndMalformed(t *testing.T) {
+	input := strings.Join(Columns, ",") + "\n" +
+		"alice,vless,,,\n" +
+		"bob,vless,,\n" +
+		"alice,vless,,,\n"
+
+	rows, err := ParseUsers(strings.NewReader(input), []string{"vless"}, testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+	if rows[0].Err != nil {
+		t.Errorf("first row error = %v", rows[0].Err)
+	}
+	if rows[1].Err == nil {
+		t.Error("row with a missing field is valid")
+	}
+	if rows[2].Err == nil || !strings.Contains(rows[2].Err.Error(), "repeated from line 2") {
+		t.Errorf("repeated row error = %v", rows[2].Err)
+	}
+	if valid := Valid(rows); len(valid) != 1 || valid[0].Username != "alice" {
+		t.Errorf("Valid = %+v, want only alice", valid)
+	}
+}
+
+func TestParseUsersTooManyRows(t *testing.T) {
+	var input strings.Builder
+	input.WriteString(strings.Join(Columns, ",") + "\n")
+	for range MaxRows + 1 {
+		input.WriteString("alice,vless,,,\n")
+	}
+	if _, err := ParseUsers(strings.NewReader(input.String()), []string{"vless"}, testNow); err == nil {
+		t.Errorf("more than %d rows are accepted", MaxRows)
+	}
+}
+
+func TestRejectUnsupported(t *testing.T) {
+	plain := repo.UserCreateData{Username: "plain", ProxyNames: []string{"vless"}}
+	limited := repo.UserCreateData{Username: "limited", ProxyNames: []string{"vless"}, DataLimit: 1 << 30}
+	noted := repo.UserCreateData{Username: "noted", ProxyNames: []string{"vless"}, Note: "vip"}
+	multi := repo.UserCreateData{Username: "multi", ProxyNames: []string{"vless", "vmess"}}
+
+	tests := []struct {
+		name      string
+		supported []repo.UserOperation
+		valid     []string
+	}{
+		{
+			name:      "everything supported",
+			supported: []repo.UserOperation{repo.USER_OPERATION_CREATE_LIMITS, repo.USER_OPERATION_CREATE_MULTI_PROXY},
+			valid:     []string{"plain", "limited", "noted", "multi"},
+		},
+		{
+			name:      "no limits",
+			supported: []repo.UserOperation{repo.USER_OPERATION_CREATE_MULTI_PROXY},
+			valid:     []string{"plain", "multi"},
+		},
+		{
+			name:      "single proxy",
+			supported: []repo.UserOperation{repo.USER_OPERATION_CREATE_LIMITS},
+			valid:     []string{"plain", "limited", "noted"},
+		},
+		{
+			name:  "nothing supported",
+			valid: []string{"plain"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := []Row{
+				{Line: 2, User: plain},
+				{Line: 3, User: limited},
+				{Line: 4, User: noted},
+				{Line: 5, User: multi},
+				{Line: 6, Err: errors.New("already invalid")},
+			}
+			RejectUnsupported(rows, limitedRepository{supported: tt.supported})
+
+			valid := make([]string, 0, len(rows))
+			for _, u := range Valid(rows) {
+				valid = append(valid, u.Username)
+			}
+			if !slices.Equal(valid, tt.valid) {
+				t.Errorf("valid users = %v, want %v", valid, tt.valid)
+			}
+			if rows[4].Err.Error() != "already invalid" {
+				t.Errorf("error of an invalid row is replaced with %v", rows[4].Err)
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	userRepository := repo.NewMemoryUserRepository(repo.UserData{UserCreateData: repo.UserCreateData{Username: "bob"}})
+	users := []repo.UserCreateData{
+		{Username: "alice", ProxyNames: []string{"vless"}},
+		{Username: "bob", ProxyNames: []string{"vless"}},
+		{Username: "carol", ProxyNames: []string{"vmess"}},
+	}
+
+	results := Create(userRepository, users, 2)
+	for i, want := range []bool{true, false, true} {
+		if results[i].Username != users[i].Username {
+			t.Errorf("result %d is for %s, want %s", i, results[i].Username, users[i].Username)
+		}
+		if (results[i].Err == nil) != want {
+			t.Errorf("result %d error = %v, want success %t", i, results[i].Err, want)
+		}
+	}
+
+	var report strings.Builder
+	if err := WriteResults(&report, results); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	if len(lines) != 4 || lines[0] != "username,status,config_urls,error" || !strings.HasPrefix(lines[2], "bob,failed,,") {
+		t.Errorf("unexpected report:\n%s", report.String())
+	}
+}
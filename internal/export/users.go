@@ -0,0 +1,216 @@
+// Package export writes user lists as CSV, JSON or XLSX documents.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+)
+
+type Format string
+
+const (
+	FORMAT_CSV  Format = "csv"
+	FORMAT_JSON Format = "json"
+	FORMAT_XLSX Format = "xlsx"
+)
+
+var Formats = []Format{FORMAT_CSV, FORMAT_JSON, FORMAT_XLSX}
+
+// Column is a user field that can be exported. Value returns a string,
+// an int64 or a []string, so that JSON keeps numbers and lists typed.
+type Column struct {
+	ID    string
+	Title string
+	Value func(repo.UserData) any
+}
+
+var Columns = []Column{
+	{"username", "Username", func(u repo.UserData) any { return u.Username }},
+	{"status", "Status", func(u repo.UserData) any { return string(u.Status) }},
+	{"used_traffic", "Used traffic, bytes", func(u repo.UserData) any { return u.UsedTraffic }},
+	{"proxies", "Proxies", func(u repo.UserData) any {
+		proxies := make([]string, 0, len(u.Proxies))
+		for _, p := range u.Proxies {
+			proxies = append(proxies, p.ProxyName)
+		}
+		return proxies
+	}},
+	{"config_urls", "Config URLs", func(u repo.UserData) any { return u.ConfigUrls }},
+}
+
+// ColumnsByID returns columns with the given IDs in the order of Columns.
+func ColumnsByID(ids []string) []Column {
+	columns := make([]Column, 0, len(ids))
+	for _, c := range Columns {
+		if slices.Contains(ids, c.ID) {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+// Writer writes users one row or object at a time, so that an export
+// does not need the whole user list up front. Close finishes the document.
+type Writer interface {
+	WriteUser(u repo.UserData) error
+	Close() error
+}
+
+// NewWriter starts a document in the format and writes its header.
+func NewWriter(w io.Writer, format Format, columns []Column) (Writer, error) {
+	switch format {
+	case FORMAT_CSV:
+		return newCSVWriter(w, columns)
+	case FORMAT_JSON:
+		return newJSONWriter(w, columns)
+	case FORMAT_XLSX:
+		return newXLSXWriter(w, columns)
+	default:
+		return nil, fmt.Errorf("unknown export format '%s'", format)
+	}
+}
+
+type csvWriter struct {
+	writer  *csv.Writer
+	columns []Column
+}
+
+func newCSVWriter(w io.Writer, columns []Column) (*csvWriter, error) {
+	writer := csv.NewWriter(w)
+	header := make([]string, 0, len(columns))
+	for _, c := range columns {
+		header = append(header, c.ID)
+	}
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	return &csvWriter{writer: writer, columns: columns}, nil
+}
+
+func (cw *csvWriter) WriteUser(u repo.UserData) error {
+	record := make([]string, 0, len(cw.columns))
+	for _, c := range cw.columns {
+		record = append(record, text(c.Value(u)))
+	}
+	return cw.writer.Write(record)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.writer.Flush()
+	return cw.writer.Error()
+}
+
+// jsonWriter writes an indented array element by element.
+type jsonWriter struct {
+	w       io.Writer
+	columns []Column
+	count   int
+}
+
+func newJSONWriter(w io.Writer, columns []Column) (*jsonWriter, error) {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return nil, err
+	}
+	return &jsonWriter{w: w, columns: columns}, nil
+}
+
+func (jw *jsonWriter) WriteUser(u repo.UserData) error {
+	object := make(map[string]any, len(jw.columns))
+	for _, c := range jw.columns {
+		object[c.ID] = c.Value(u)
+	}
+	data, err := json.MarshalIndent(object, "  ", "  ")
+	if err != nil {
+		return err
+	}
+
+	separator := "\n  "
+	if jw.count > 0 {
+		separator = "," + separator
+	}
+	jw.count++
+	if _, err := io.WriteString(jw.w, separator); err != nil {
+		return err
+	}
+	_, err = jw.w.Write(data)
+	return err
+}
+
+func (jw *jsonWriter) Close() error {
+	end := "\n]\n"
+	if jw.count == 0 {
+		end = "]\n"
+	}
+	_, err := io.WriteString(jw.w, end)
+	return err
+}
+
+// xlsxWriter streams rows into the sheet, so that large panels
+// do not keep every cell in memory twice. The archive itself
+// can only be written once the sheet is complete.
+type xlsxWriter struct {
+	w       io.Writer
+	file    *excelize.File
+	stream  *excelize.StreamWriter
+	columns []Column
+	row     int
+}
+
+func newXLSXWriter(w io.Writer, columns []Column) (*xlsxWriter, error) {
+	f := excelize.NewFile()
+	stream, err := f.NewStreamWriter(f.GetSheetName(0))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	header := make([]any, 0, len(columns))
+	for _, c := range columns {
+		header = append(header, c.Title)
+	}
+	if err := stream.SetRow("A1", header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &xlsxWriter{w: w, file: f, stream: stream, columns: columns, row: 1}, nil
+}
+
+func (xw *xlsxWriter) WriteUser(u repo.UserData) error {
+	row := make([]any, 0, len(xw.columns))
+	for _, c := range xw.columns {
+		value := c.Value(u)
+		if _, ok := value.(int64); !ok {
+			value = text(value)
+		}
+		row = append(row, value)
+	}
+	xw.row++
+	cell, err := excelize.CoordinatesToCellName(1, xw.row)
+	if err != nil {
+		return err
+	}
+	return xw.stream.SetRow(cell, row)
+}
+
+func (xw *xlsxWriter) Close() error {
+	defer xw.file.Close()
+	if err := xw.stream.Flush(); err != nil {
+		return err
+	}
+	return xw.file.Write(xw.w)
+}
+
+func text(value any) string {
+	if list, ok := value.([]string); ok {
+		return strings.Join(list, " ")
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,163 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+)
+
+var testUsers = []repo.UserData{
+	{
+		UserCreateData: repo.UserCreateData{Username: "alice"},
+		Status:         repo.USER_STATUS_ACTIVE,
+		UsedTraffic:    1 << 30,
+		ConfigUrls:     []string{"vless://a", "vmess://b"},
+		Proxies:        []repo.ProxyData{{ProxyName: "vless"}, {ProxyName: "vmess"}},
+	},
+	{
+		UserCreateData: repo.UserCreateData{Username: "bob"},
+		Status:         repo.USER_STATUS_DISABLED,
+	},
+}
+
+func TestColumnsByID(t *testing.T) {
+	tests := []struct {
+		ids  []string
+		want []string
+	}{
+		{ids: []string{"username"}, want: []string{"username"}},
+		{ids: []string{"proxies", "username", "status"}, want: []string{"username", "status", "proxies"}},
+		{ids: []string{"username", "password", "data_limit"}, want: []string{"username"}},
+		{ids: nil, want: []string{}},
+	}
+	for _, tt := range tests {
+		got := make([]string, 0, len(tt.want))
+		for _, c := range ColumnsByID(tt.ids) {
+			got = append(got, c.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ColumnsByID(%v) = %v, want %v", tt.ids, got, tt.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	columns := ColumnsByID([]string{"username", "used_traffic", "proxies", "config_urls"})
+
+	tests := []struct {
+		format Format
+		check  func(t *testing.T, output []byte)
+	}{
+		{
+			format: FORMAT_CSV,
+			check: func(t *testing.T, output []byte) {
+				records, err := csv.NewReader(bytes.NewReader(output)).ReadAll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := [][]string{
+					{"username", "used_traffic", "proxies", "config_urls"},
+					{"alice", "1073741824", "vless vmess", "vless://a vmess://b"},
+					{"bob", "0", "", ""},
+				}
+				if !slices.EqualFunc(records, want, slices.Equal) {
+					t.Errorf("records = %q, want %q", records, want)
+				}
+			},
+		},
+		{
+			format: FORMAT_JSON,
+			check: func(t *testing.T, output []byte) {
+				var objects []struct {
+					Username    string   `json:"username"`
+					UsedTraffic int64    `json:"used_traffic"`
+					Proxies     []string `json:"proxies"`
+					ConfigUrls  []string `json:"config_urls"`
+				}
+				if err := json.Unmarshal(output, &objects); err != nil {
+					t.Fatal(err)
+				}
+				if len(objects) != 2 {
+					t.Fatalf("got %d objects, want 2", len(objects))
+				}
+				alice := objects[0]
+				if alice.Username != "alice" || alice.UsedTraffic != 1<<30 ||
+					!slices.Equal(alice.Proxies, []string{"vless", "vmess"}) || !slices.Equal(alice.ConfigUrls, []string{"vless://a", "vmess://b"}) {
+					t.Errorf("alice = %+v", alice)
+				}
+				if bob := objects[1]; bob.Username != "bob" || len(bob.Proxies) != 0 {
+					t.Errorf("bob = %+v", bob)
+				}
+			},
+		},
+		{
+			format: FORMAT_XLSX,
+			check: func(t *testing.T, output []byte) {
+				f, err := excelize.OpenReader(bytes.NewReader(output))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+
+				rows, err := f.GetRows(f.GetSheetName(0))
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := [][]string{
+					{"Username", "Used traffic, bytes", "Proxies", "Config URLs"},
+					{"alice", "1073741824", "vless vmess", "vless://a vmess://b"},
+					{"bob", "0"},
+				}
+				if !slices.EqualFunc(rows, want, slices.Equal) {
+					t.Errorf("rows = %q, want %q", rows, want)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var output bytes.Buffer
+			if err := write(&output, tt.format, columns, testUsers); err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, output.Bytes())
+		})
+	}
+}
+
+func TestWriteEmptyJSON(t *testing.T) {
+	var output bytes.Buffer
+	if err := write(&output, FORMAT_JSON, Columns, nil); err != nil {
+		t.Fatal(err)
+	}
+	var objects []map[string]any
+	if err := json.Unmarshal(output.Bytes(), &objects); err != nil || objects == nil || len(objects) != 0 {
+		t.Errorf("output = %q, want an empty array", output.String())
+	}
+}
+
+func TestNewWriterUnknownFormat(t *testing.T) {
+	var output bytes.Buffer
+	if _, err := NewWriter(&output, Format("pdf"), Columns); err == nil {
+		t.Error("unknown format is accepted")
+	}
+}
+
+func write(w *bytes.Buffer, format Format, columns []Column, users []repo.UserData) error {
+	writer, err := NewWriter(w, format, columns)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if err := writer.WriteUser(u); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
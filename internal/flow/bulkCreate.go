@@ -0,0 +1,198 @@
+package flow
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/luckyComet55/marzban-tg-bot/internal/bulk"
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+)
+
+const (
+	bulkMaxFileSize      = 1 << 20
+	bulkConcurrency      = 4
+	bulkShownInvalidRows = 20
+)
+
+func (af *AdminFlow) registerBulkCreate(f *fsm.FSM) {
+	f.OnEnter(repo.ADMIN_STATE_BULK_CREATE_UPLOAD, af.enterBulkUpload)
+	f.OnExit(repo.ADMIN_STATE_BULK_CREATE_UPLOAD, af.exitBulkUpload)
+	f.OnEnter(repo.ADMIN_STATE_BULK_CREATE_CONFIRM, af.enterBulkConfirm)
+	f.OnExit(repo.ADMIN_STATE_BULK_CREATE_CONFIRM, af.exitBulkConfirm)
+}
+
+func (af *AdminFlow) enterBulkUpload(ctx *fsm.FSMContext) error {
+	kb := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{
+				{Text: "Cancel", CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_BACK)},
+			},
+		},
+	}
+
+	text := fmt.Sprintf("Send a CSV document with the header\n%s\n\n"+
+		"Separate several proxies with ';'. Empty data_limit means unlimited, empty expire means never. "+
+		"At most %d rows are accepted", strings.Join(bulk.Columns, ","), bulk.MaxRows)
+	if !af.userRepository.Supports(repo.USER_OPERATION_CREATE_LIMITS) {
+		text += ".\nThe panel does not support data_limit, expire and note on creation yet, leave them empty"
+	}
+
+	return af.send(metaOf(ctx), &bot.SendMessageParams{
+		Text:        text,
+		ReplyMarkup: kb,
+	})
+}
+
+// exitBulkUpload downloads the uploaded CSV and validates it. Nothing is
+// created yet, the rows are kept for the dry-run summary.
+func (af *AdminFlow) exitBulkUpload(ctx *fsm.FSMContext) error {
+	if ctx.Event != repo.ADMIN_EVENT_NEXT {
+		return nil
+	}
+
+	update, _ := ctx.Meta["tgmes"].(*models.Update)
+	if update == nil || update.Message == nil || update.Message.Document == nil {
+		return inputErrorf("Send the users as a CSV document or press Cancel")
+	}
+	document := update.Message.Document
+	if document.FileSize > bulkMaxFileSize {
+		return inputErrorf("The document is too large, at most %d KB are accepted", bulkMaxFileSize>>10)
+	}
+
+	content, err := af.download(metaOf(ctx), document.FileID)
+	if err != nil {
+		af.logger.Error(err.Error())
+		return inputErrorf("Could not download the document, try again later")
+	}
+
+	proxies, err := af.proxyRepository.ListProxies()
+	if err != nil {
+		af.logger.Error(err.Error())
+		return inputErrorf("Unable to serve you right now, try again later")
+	}
+	proxyNames := make([]string, 0, len(proxies))
+	for _, p := range proxies {
+		proxyNames = append(proxyNames, p.ProxyName)
+	}
+
+	rows, err := bulk.ParseUsers(bytes.NewReader(content), proxyNames, time.Now())
+	if err != nil {
+		return inputErrorf("Could not read the CSV: %s", err)
+	}
+	if len(rows) == 0 {
+		return inputErrorf("The CSV has no users")
+	}
+	bulk.RejectUnsupported(rows, af.userRepository)
+
+	ctx.Data["bulk_rows"] = rows
+	return nil
+}
+
+func (af *AdminFlow) download(m tgMeta, fileID string) ([]byte, error) {
+	file, err := m.bot.GetFile(m.ctx, &bot.GetFileParams{FileID: fileID})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(m.ctx, http.MethodGet, m.bot.FileDownloadLink(file), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading file %s: %s", file.FilePath, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, bulkMaxFileSize))
+}
+
+func (af *AdminFlow) enterBulkConfirm(ctx *fsm.FSMContext) error {
+	rows := ctx.Data["bulk_rows"].([]bulk.Row)
+	valid := len(bulk.Valid(rows))
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "Rows: %d, valid: %d, invalid: %d\n", len(rows), valid, len(rows)-valid)
+	shown := 0
+	for _, row := range rows {
+		if row.Err == nil {
+			continue
+		}
+		if shown == bulkShownInvalidRows {
+			fmt.Fprintf(&summary, "…and %d more\n", len(rows)-valid-shown)
+			break
+		}
+		fmt.Fprintf(&summary, "Line %d %s: %s\n", row.Line, row.User.Username, row.Err)
+		shown++
+	}
+	if valid > 0 {
+		summary.WriteString("\nInvalid rows are skipped. Nothing is created until you confirm")
+	}
+
+	rowsKb := make([][]models.InlineKeyboardButton, 0, 2)
+	if valid > 0 {
+		rowsKb = append(rowsKb, []models.InlineKeyboardButton{
+			{Text: fmt.Sprintf("Create %d users", valid), CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_CONFIRM_BULK)},
+		})
+	}
+	rowsKb = append(rowsKb, []models.InlineKeyboardButton{
+		{Text: "Cancel", CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_BACK)},
+	})
+
+	return af.send(metaOf(ctx), &bot.SendMessageParams{
+		Text:        summary.String(),
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: rowsKb},
+	})
+}
+
+// exitBulkConfirm creates the valid users and sends back a CSV
+// with the config URLs of created users and the errors of failed ones.
+func (af *AdminFlow) exitBulkConfirm(ctx *fsm.FSMContext) error {
+	rows, _ := ctx.Data["bulk_rows"].([]bulk.Row)
+	delete(ctx.Data, "bulk_rows")
+	if ctx.Event != repo.ADMIN_EVENT_CONFIRM_BULK {
+		return nil
+	}
+	m := metaOf(ctx)
+
+	users := bulk.Valid(rows)
+	af.send(m, &bot.SendMessageParams{
+		Text: fmt.Sprintf("Creating %d users…", len(users)),
+	})
+
+	results := bulk.Create(af.userRepository, users, bulkConcurrency)
+	created := 0
+	for _, result := range results {
+		if result.Err != nil {
+			af.logger.Error(result.Err.Error())
+			continue
+		}
+		created++
+	}
+	af.logger.Info(fmt.Sprintf("bulk created %d of %d users", created, len(results)))
+
+	var report bytes.Buffer
+	if err := bulk.WriteResults(&report, results); err != nil {
+		af.logger.Error(err.Error())
+		return err
+	}
+	if _, err := m.bot.SendDocument(m.ctx, &bot.SendDocumentParams{
+		ChatID:   m.chatID,
+		Document: &models.InputFileUpload{Filename: fmt.Sprintf("bulk-%s.csv", time.Now().Format("20060102-150405")), Data: &report},
+		Caption:  fmt.Sprintf("Created %d of %d users", created, len(results)),
+	}); err != nil {
+		af.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package flow
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/clientconfig"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+)
+
+type clientConfig struct {
+	id        string
+	name      string
+	extension string
+	build     func([]clientconfig.Outbound) ([]byte, error)
+}
+
+// clientConfigs are the client apps a user's config URLs can be exported for.
+var clientConfigs = []clientConfig{
+	{"singbox", "sing-box", "json", clientconfig.SingBox},
+	{"clash", "Clash", "yaml", clientconfig.Clash},
+	{"v2ray", "v2ray", "json", clientconfig.V2Ray},
+}
+
+func (af *AdminFlow) registerClientConfigs(f *fsm.FSM) {
+	f.OnTransition(af.sendClientConfig)
+}
+
+// sendClientConfig converts the config URLs of the user shown on the card
+// into a config file of the chosen client and sends it as a document.
+func (af *AdminFlow) sendClientConfig(from, to fsm.State, event fsm.Event, ctx *fsm.FSMContext) error {
+	if event != repo.ADMIN_EVENT_CLIENT_CONFIG {
+		return nil
+	}
+	m := metaOf(ctx)
+
+	id := ctx.Input.(string)
+	i := slices.IndexFunc(clientConfigs, func(c clientConfig) bool { return c.id == id })
+	if i < 0 {
+		return fmt.Errorf("unknown client config '%s'", id)
+	}
+	client := clientConfigs[i]
+
+	username := ctx.Data["details_username"].(string)
+	user, err := af.userRepository.GetUser(username)
+	if err != nil {
+		af.logger.Error(err.Error())
+		if errors.Is(err, repo.ErrUserNotFound) {
+			return inputErrorf("User %s not found", username)
+		}
+		return inputErrorf("Could not get config URLs of %s, try again later", username)
+	}
+	if len(user.ConfigUrls) == 0 {
+		return inputErrorf("User %s has no config URLs", username)
+	}
+
+	outbounds, err := clientconfig.ParseAll(user.ConfigUrls)
+	if err != nil {
+		af.logger.Error(err.Error())
+		return inputErrorf("Could not convert config URLs of %s: %s", username, err)
+	}
+	config, err := client.build(outbounds)
+	if err != nil {
+		af.logger.Error(err.Error())
+		return inputErrorf("Could not build %s config for %s, try again later", client.name, username)
+	}
+
+	if _, err := m.bot.SendDocument(m.ctx, &bot.SendDocumentParams{
+		ChatID:   m.chatID,
+		Document: &models.InputFileUpload{Filename: fmt.Sprintf("%s-%s.%s", username, client.id, client.extension), Data: bytes.NewReader(config)},
+		Caption:  fmt.Sprintf("%s config for %s", client.name, username),
+	}); err != nil {
+		af.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
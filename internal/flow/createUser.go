@@ -0,0 +1,257 @@
+package flow
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/luckyComet55/marzban-tg-bot/internal/preset"
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/internal/usermeta"
+	"github.com/luckyComet55/marzban-tg-bot/internal/validation"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+)
+
+func (af *AdminFlow) registerCreateUser(f *fsm.FSM) {
+	f.OnEnter(repo.ADMIN_STATE_CREATE_USER_SELECT_TEMPLATE, af.enterSelectTemplate)
+	f.OnExit(repo.ADMIN_STATE_CREATE_USER_SELECT_TEMPLATE, af.exitSelectTemplate)
+	f.OnEnter(repo.ADMIN_STATE_CREATE_USER_INPUT_NAME, af.enterInputName)
+	f.OnExit(repo.ADMIN_STATE_CREATE_USER_INPUT_NAME, af.exitInputName)
+	f.OnEnter(repo.ADMIN_STATE_CREATE_USER_SELECT_PROXY, af.enterSelectProxy)
+	f.OnExit(repo.ADMIN_STATE_CREATE_USER_SELECT_PROXY, af.exitSelectProxy)
+	f.OnEnter(repo.ADMIN_STATE_CREATE_USER_SUBMIT_DATA, af.enterSubmitData)
+	f.OnTransition(af.submitCreateUser)
+}
+
+func (af *AdminFlow) enterSelectTemplate(ctx *fsm.FSMContext) error {
+	presets := af.presets.List()
+
+	rows := make([][]models.InlineKeyboardButton, 0, len(presets)+1)
+	for _, p := range presets {
+		if !af.canUsePreset(p) {
+			continue
+		}
+		rows = append(rows, []models.InlineKeyboardButton{{Text: fmt.Sprintf("%s: %s", p.Name, p.Summary()), CallbackData: fmt.Sprintf("%s:%s", repo.ADMIN_EVENT_USE_TEMPLATE, p.Name)}})
+	}
+	rows = append(rows, []models.InlineKeyboardButton{
+		{Text: "Custom", CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_USE_TEMPLATE)},
+		{Text: "Cancel", CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_CANCEL)},
+	})
+
+	return af.send(metaOf(ctx), &bot.SendMessageParams{
+		Text:        "Select a template or create a custom user",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: rows},
+	})
+}
+
+// exitSelectTemplate fills the proxy, data limit and expiry answers
+// from the template, so that the wizard goes from the name to submit.
+func (af *AdminFlow) exitSelectTemplate(ctx *fsm.FSMContext) error {
+	if ctx.Event != repo.ADMIN_EVENT_USE_TEMPLATE {
+		return nil
+	}
+	delete(ctx.Data, "user_meta")
+
+	name := ctx.Input.(string)
+	if name == "" {
+		delete(ctx.Data, "template")
+		delete(ctx.Data, "data_limit")
+		delete(ctx.Data, "expire")
+		ctx.Data["proxies"] = []string{}
+		return nil
+	}
+
+	p, err := af.presets.Get(name)
+	if err != nil {
+		af.logger.Error(err.Error())
+		return inputErrorf("Template %s does not exist anymore", name)
+	}
+	if !af.canUsePreset(p) {
+		return inputErrorf("Template %s uses settings the panel does not support on creation yet", name)
+	}
+	expire, err := p.ExpireAt(time.Now())
+	if err != nil {
+		return inputErrorf("Template %s can not be used: %s", name, err)
+	}
+	ctx.Data["template"] = p.Name
+	ctx.Data["proxies"] = slices.Clone(p.ProxyNames)
+	ctx.Data["data_limit"] = p.DataLimit
+	ctx.Data["expire"] = expire
+	return nil
+}
+
+// canUsePreset reports whether users shaped like the preset can be created.
+func (af *AdminFlow) canUsePreset(p preset.Preset) bool {
+	if (p.DataLimit != 0 || p.Expire != "") && !af.userRepository.Supports(repo.USER_OPERATION_CREATE_LIMITS) {
+		return false
+	}
+	return len(p.ProxyNames) <= 1 || af.userRepository.Supports(repo.USER_OPERATION_CREATE_MULTI_PROXY)
+}
+
+func withTemplate(ctx *fsm.FSMContext) bool {
+	_, ok := ctx.Data["template"]
+	return ok
+}
+
+func withoutTemplate(ctx *fsm.FSMContext) bool {
+	return !withTemplate(ctx)
+}
+
+func (af *AdminFlow) enterInputName(ctx *fsm.FSMContext) error {
+	m := metaOf(ctx)
+
+	af.send(m, &bot.SendMessageParams{
+		Text: "Input username. It must be 3-32 symbols [a-zA-Z0-9_]",
+	})
+
+	return nil
+}
+
+func (af *AdminFlow) exitInputName(ctx *fsm.FSMContext) error {
+	userName := ctx.Input.(string)
+
+	if err := validation.Username(userName); err != nil {
+		return inputErrorf("Invalid username: %s, try again", err)
+	}
+
+	ctx.Data["username"] = userName
+	return nil
+}
+
+func (af *AdminFlow) enterSelectProxy(ctx *fsm.FSMContext) error {
+	proxies, err := af.proxyRepository.ListProxies()
+	if err != nil {
+		af.logger.Error(err.Error())
+		return err
+	}
+
+	rows := make([][]models.InlineKeyboardButton, 0, len(proxies)+1)
+	for _, p := range proxies {
+		rows = append(rows, []models.InlineKeyboardButton{{Text: p.ProxyName, CallbackData: fmt.Sprintf("%s:%s", repo.ADMIN_EVENT_USE_PROXY, p.ProxyName)}})
+	}
+	rows = append(rows, []models.InlineKeyboardButton{
+		{Text: "Cancel", CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_CANCEL)},
+	})
+
+	return af.send(metaOf(ctx), &bot.SendMessageParams{
+		Text:        "Select user proxy configuration from list",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: rows},
+	})
+}
+
+// exitSelectProxy keeps the clicked proxy. The management contract
+// creates users with a single proxy, so there is nothing to toggle.
+func (af *AdminFlow) exitSelectProxy(ctx *fsm.FSMContext) error {
+	if ctx.Event == repo.ADMIN_EVENT_USE_PROXY {
+		ctx.Data["proxies"] = []string{ctx.Input.(string)}
+	}
+	return nil
+}
+
+// createDataOf collects the answers of the create user wizard.
+func createDataOf(ctx *fsm.FSMContext) repo.UserCreateData {
+	limit, _ := ctx.Data["data_limit"].(int64)
+	expire, _ := ctx.Data["expire"].(time.Time)
+	return repo.UserCreateData{
+		Username:   ctx.Data["username"].(string),
+		ProxyNames: ctx.Data["proxies"].([]string),
+		DataLimit:  limit,
+		ExpireAt:   expire,
+	}
+}
+
+func (af *AdminFlow) enterSubmitData(ctx *fsm.FSMContext) error {
+	m := metaOf(ctx)
+
+	data := createDataOf(ctx)
+	text := fmt.Sprintf("Username: %s\nProxy configs: %s", data.Username, strings.Join(data.ProxyNames, ", "))
+	if data.DataLimit != 0 {
+		text += fmt.Sprintf("\nData limit: %s", formatDataLimit(data.DataLimit))
+	}
+	if !data.ExpireAt.IsZero() {
+		text += fmt.Sprintf("\nExpires: %s", formatExpiry(data.ExpireAt))
+	}
+	if template, ok := ctx.Data["template"].(string); ok {
+		text += fmt.Sprintf("\nTemplate: %s", template)
+	}
+	if meta, ok := ctx.Data["user_meta"].(usermeta.Meta); ok {
+		text += "\n" + formatUserMeta(meta)
+	}
+
+	kb := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{
+				{Text: "Submit", CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_SUBMIT_CREATE)},
+			},
+			{
+				{Text: "Cancel", CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_CANCEL)},
+			},
+		},
+	}
+
+	af.send(m, &bot.SendMessageParams{
+		Text:        text,
+		ReplyMarkup: kb,
+	})
+
+	return nil
+}
+
+func (af *AdminFlow) submitCreateUser(from, to fsm.State, event fsm.Event, ctx *fsm.FSMContext) error {
+	if event != repo.ADMIN_EVENT_SUBMIT_CREATE {
+		return nil
+	}
+	m := metaOf(ctx)
+
+	userData, err := af.userRepository.CreateUser(createDataOf(ctx))
+	if err != nil {
+		text := "Could not add user, try again later"
+		if errors.Is(err, repo.ErrNotSupported) {
+			text = "The panel does not support some of the selected settings on creation yet"
+		}
+		af.send(m, &bot.SendMessageParams{
+			Text: text,
+		})
+		return err
+	}
+
+	if meta, ok := ctx.Data["user_meta"].(usermeta.Meta); ok {
+		if err := af.userMeta.Set(userData.Username, meta); err != nil {
+			af.logger.Error(err.Error())
+			af.send(m, &bot.SendMessageParams{
+				Text: fmt.Sprintf("User %s is created, but the bot could not save their notes and tags", userData.Username),
+			})
+		}
+	}
+
+	var kb models.ReplyMarkup
+	if len(userData.ConfigUrls) > 0 {
+		kb = &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{
+				{
+					{Text: "QR codes", CallbackData: fmt.Sprintf("%s:%s", repo.ADMIN_EVENT_QR_CODES, userData.Username)},
+				},
+			},
+		}
+	}
+
+	userFormat := "Created user:\nusername: %s\nstatus: %s%s\nproxy configs: %s\n\nConfig URLs:\n%s"
+	af.send(m, &bot.SendMessageParams{
+		Text: fmt.Sprintf(userFormat,
+			html.EscapeString(userData.Username),
+			statusBadge(userData.Status),
+			html.EscapeString(string(userData.Status)),
+			html.EscapeString(strings.Join(userData.ProxyNames, ", ")),
+			formatConfigUrls(userData.ConfigUrls),
+		),
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: kb,
+	})
+	return nil
+}
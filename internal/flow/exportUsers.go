@@ -0,0 +1,142 @@
+package flow
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/luckyComet55/marzban-tg-bot/internal/export"
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+)
+
+// The export screen is opened with the /export command,
+// which puts the admin straight into ADMIN_STATE_EXPORT_USERS.
+func (af *AdminFlow) registerExportUsers(f *fsm.FSM) {
+	f.OnEnter(repo.ADMIN_STATE_EXPORT_USERS, af.enterExportUsers)
+	f.OnExit(repo.ADMIN_STATE_EXPORT_USERS, af.exitExportUsers)
+}
+
+func (af *AdminFlow) enterExportUsers(ctx *fsm.FSMContext) error {
+	if ctx.Event != repo.ADMIN_EVENT_EXPORT_FORMAT && ctx.Event != repo.ADMIN_EVENT_EXPORT_COLUMN {
+		ctx.Data["export_format"] = export.FORMAT_CSV
+		columns := make([]string, 0, len(export.Columns))
+		for _, c := range export.Columns {
+			columns = append(columns, c.ID)
+		}
+		ctx.Data["export_columns"] = columns
+	}
+	format := ctx.Data["export_format"].(export.Format)
+	columns := ctx.Data["export_columns"].([]string)
+
+	formatRow := make([]models.InlineKeyboardButton, 0, len(export.Formats))
+	for _, f := range export.Formats {
+		text := strings.ToUpper(string(f))
+		if f == format {
+			text += " ✓"
+		}
+		formatRow = append(formatRow, models.InlineKeyboardButton{Text: text, CallbackData: fmt.Sprintf("%s:%s", repo.ADMIN_EVENT_EXPORT_FORMAT, f)})
+	}
+
+	rows := [][]models.InlineKeyboardButton{formatRow}
+	for chunk := range slices.Chunk(export.Columns, 2) {
+		row := make([]models.InlineKeyboardButton, 0, len(chunk))
+		for _, c := range chunk {
+			text := c.Title
+			if slices.Contains(columns, c.ID) {
+				text = "✓ " + text
+			}
+			row = append(row, models.InlineKeyboardButton{Text: text, CallbackData: fmt.Sprintf("%s:%s", repo.ADMIN_EVENT_EXPORT_COLUMN, c.ID)})
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows, []models.InlineKeyboardButton{
+		{Text: "Export", CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_EXPORT)},
+		{Text: "Cancel", CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_BACK)},
+	})
+
+	return af.render(ctx, "export_message", &bot.SendMessageParams{
+		Text:        "Select the file format and the columns to export",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: rows},
+	})
+}
+
+func (af *AdminFlow) exitExportUsers(ctx *fsm.FSMContext) error {
+	switch ctx.Event {
+	case repo.ADMIN_EVENT_EXPORT_FORMAT:
+		format := export.Format(ctx.Input.(string))
+		if !slices.Contains(export.Formats, format) {
+			return fmt.Errorf("unknown export format '%s'", format)
+		}
+		ctx.Data["export_format"] = format
+	case repo.ADMIN_EVENT_EXPORT_COLUMN:
+		columns := ctx.Data["export_columns"].([]string)
+		column := ctx.Input.(string)
+		if i := slices.Index(columns, column); i >= 0 {
+			columns = slices.Delete(columns, i, i+1)
+		} else {
+			columns = append(columns, column)
+		}
+		ctx.Data["export_columns"] = columns
+	case repo.ADMIN_EVENT_EXPORT:
+		return af.sendExport(ctx)
+	}
+	return nil
+}
+
+func (af *AdminFlow) sendExport(ctx *fsm.FSMContext) error {
+	m := metaOf(ctx)
+	format := ctx.Data["export_format"].(export.Format)
+	columns := export.ColumnsByID(ctx.Data["export_columns"].([]string))
+	if len(columns) == 0 {
+		return inputErrorf("Select at least one column")
+	}
+
+	users, err := af.userRepository.GetUsers()
+	if err != nil {
+		af.logger.Error(err.Error())
+		return inputErrorf("Unable to serve you right now, try again later")
+	}
+	slices.SortFunc(users, func(a, b repo.UserShortData) int {
+		return strings.Compare(a.Username, b.Username)
+	})
+
+	// Details are fetched and written one user at a time,
+	// so that only the current row is kept besides the document.
+	var document bytes.Buffer
+	writer, err := export.NewWriter(&document, format, columns)
+	if err != nil {
+		af.logger.Error(err.Error())
+		return inputErrorf("Could not export users, try again later")
+	}
+	for _, u := range users {
+		details, err := af.userRepository.GetUser(u.Username)
+		if err == nil {
+			err = writer.WriteUser(details)
+		}
+		if err != nil {
+			writer.Close()
+			af.logger.Error(err.Error())
+			return inputErrorf("Could not export users, try again later")
+		}
+	}
+	if err := writer.Close(); err != nil {
+		af.logger.Error(err.Error())
+		return inputErrorf("Could not export users, try again later")
+	}
+
+	if _, err := m.bot.SendDocument(m.ctx, &bot.SendDocumentParams{
+		ChatID:   m.chatID,
+		Document: &models.InputFileUpload{Filename: fmt.Sprintf("users-%s.%s", time.Now().Format("20060102-150405"), format), Data: &document},
+		Caption:  fmt.Sprintf("Total of %d users", len(users)),
+	}); err != nil {
+		af.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
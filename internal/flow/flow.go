@@ -0,0 +1,168 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/luckyComet55/marzban-tg-bot/internal/auth"
+	"github.com/luckyComet55/marzban-tg-bot/internal/preset"
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/internal/usermeta"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+)
+
+// AdminFlow describes the conversation with an admin: the states
+// of the admin FSM, transitions between them and the messages
+// the bot sends on the way.
+type AdminFlow struct {
+	logger          *slog.Logger
+	userRepository  repo.UserRepository
+	proxyRepository repo.ProxyRepository
+	presets         *preset.Store
+	userMeta        *usermeta.Store
+}
+
+func NewAdminFlow(userRepo repo.UserRepository, proxyRepo repo.ProxyRepository, presets *preset.Store, userMeta *usermeta.Store, logger *slog.Logger) *AdminFlow {
+	return &AdminFlow{
+		logger:          logger,
+		userRepository:  userRepo,
+		proxyRepository: proxyRepo,
+		presets:         presets,
+		userMeta:        userMeta,
+	}
+}
+
+// Build returns the FSM every admin's state machine is copied from.
+func (af *AdminFlow) Build() *fsm.FSM {
+	f := fsm.NewFSM(repo.ADMIN_STATE_DEFAULT).
+		TransitionWhen(repo.ADMIN_STATE_DEFAULT, repo.ADMIN_EVENT_LIST_USERS, repo.ADMIN_STATE_LIST_USERS, auth.Guard(repo.ADMIN_EVENT_LIST_USERS)).
+		Transition(repo.ADMIN_STATE_LIST_USERS, repo.ADMIN_EVENT_PAGE, repo.ADMIN_STATE_LIST_USERS).
+		Transition(repo.ADMIN_STATE_LIST_USERS, repo.ADMIN_EVENT_SORT, repo.ADMIN_STATE_LIST_USERS).
+		Transition(repo.ADMIN_STATE_LIST_USERS, repo.ADMIN_EVENT_BACK, repo.ADMIN_STATE_DEFAULT).
+		TransitionWhen(repo.ADMIN_STATE_DEFAULT, repo.ADMIN_EVENT_FIND_USER, repo.ADMIN_STATE_FIND_USER_INPUT_QUERY, auth.Guard(repo.ADMIN_EVENT_FIND_USER)).
+		Transition(repo.ADMIN_STATE_FIND_USER_INPUT_QUERY, repo.ADMIN_EVENT_NEXT, repo.ADMIN_STATE_LIST_USERS).
+		Transition(repo.ADMIN_STATE_FIND_USER_INPUT_QUERY, repo.ADMIN_EVENT_BACK, repo.ADMIN_STATE_DEFAULT).
+		TransitionWhen(repo.ADMIN_STATE_DEFAULT, repo.ADMIN_EVENT_LIST_PROXIES, repo.ADMIN_STATE_LIST_PROXIES, auth.Guard(repo.ADMIN_EVENT_LIST_PROXIES)).
+		Transition(repo.ADMIN_STATE_LIST_PROXIES, repo.ADMIN_EVENT_PAGE, repo.ADMIN_STATE_LIST_PROXIES).
+		Transition(repo.ADMIN_STATE_LIST_PROXIES, repo.ADMIN_EVENT_BACK, repo.ADMIN_STATE_DEFAULT).
+		TransitionWhen(repo.ADMIN_STATE_DEFAULT, repo.ADMIN_EVENT_CREATE_USER, repo.ADMIN_STATE_CREATE_USER_SELECT_TEMPLATE, auth.Guard(repo.ADMIN_EVENT_CREATE_USER)).
+		TransitionWhen(repo.ADMIN_STATE_CREATE_USER_SELECT_TEMPLATE, repo.ADMIN_EVENT_USE_TEMPLATE, repo.ADMIN_STATE_CREATE_USER_INPUT_NAME, auth.Guard(repo.ADMIN_EVENT_USE_TEMPLATE)).
+		Transition(repo.ADMIN_STATE_CREATE_USER_SELECT_TEMPLATE, repo.ADMIN_EVENT_CANCEL, repo.ADMIN_STATE_DEFAULT).
+		TransitionWhen(repo.ADMIN_STATE_CREATE_USER_INPUT_NAME, repo.ADMIN_EVENT_NEXT, repo.ADMIN_STATE_CREATE_USER_SELECT_PROXY, withoutTemplate).
+		TransitionWhen(repo.ADMIN_STATE_CREATE_USER_INPUT_NAME, repo.ADMIN_EVENT_NEXT, repo.ADMIN_STATE_CREATE_USER_META, withTemplate).
+		TransitionWhen(repo.ADMIN_STATE_CREATE_USER_SELECT_PROXY, repo.ADMIN_EVENT_USE_PROXY, repo.ADMIN_STATE_CREATE_USER_META, auth.Guard(repo.ADMIN_EVENT_USE_PROXY)).
+		Transition(repo.ADMIN_STATE_CREATE_USER_SELECT_PROXY, repo.ADMIN_EVENT_CANCEL, repo.ADMIN_STATE_DEFAULT).
+		Transition(repo.ADMIN_STATE_CREATE_USER_META, repo.ADMIN_EVENT_NEXT, repo.ADMIN_STATE_CREATE_USER_SUBMIT_DATA).
+		TransitionWhen(repo.ADMIN_STATE_CREATE_USER_META, repo.ADMIN_EVENT_SKIP, repo.ADMIN_STATE_CREATE_USER_SUBMIT_DATA, auth.Guard(repo.ADMIN_EVENT_SKIP)).
+		Transition(repo.ADMIN_STATE_CREATE_USER_META, repo.ADMIN_EVENT_CANCEL, repo.ADMIN_STATE_DEFAULT).
+		TransitionWhen(repo.ADMIN_STATE_CREATE_USER_SUBMIT_DATA, repo.ADMIN_EVENT_SUBMIT_CREATE, repo.ADMIN_STATE_DEFAULT, auth.Guard(repo.ADMIN_EVENT_SUBMIT_CREATE)).
+		Transition(repo.ADMIN_STATE_CREATE_USER_SUBMIT_DATA, repo.ADMIN_EVENT_CANCEL, repo.ADMIN_STATE_DEFAULT).
+		TransitionWhen(repo.ADMIN_STATE_DEFAULT, repo.ADMIN_EVENT_USER_DETAILS, repo.ADMIN_STATE_USER_DETAILS, auth.Guard(repo.ADMIN_EVENT_USER_DETAILS)).
+		TransitionWhen(repo.ADMIN_STATE_LIST_USERS, repo.ADMIN_EVENT_USER_DETAILS, repo.ADMIN_STATE_USER_DETAILS, auth.Guard(repo.ADMIN_EVENT_USER_DETAILS)).
+		TransitionWhen(repo.ADMIN_STATE_USER_DETAILS, repo.ADMIN_EVENT_USER_DETAILS, repo.ADMIN_STATE_USER_DETAILS, auth.Guard(repo.ADMIN_EVENT_USER_DETAILS)).
+		Transition(repo.ADMIN_STATE_USER_DETAILS, repo.ADMIN_EVENT_BACK, repo.ADMIN_STATE_LIST_USERS).
+		TransitionWhen(repo.ADMIN_STATE_DEFAULT, repo.ADMIN_EVENT_QR_CODES, repo.ADMIN_STATE_DEFAULT, auth.Guard(repo.ADMIN_EVENT_QR_CODES)).
+		TransitionWhen(repo.ADMIN_STATE_USER_DETAILS, repo.ADMIN_EVENT_QR_CODES, repo.ADMIN_STATE_USER_DETAILS, auth.Guard(repo.ADMIN_EVENT_QR_CODES)).
+		TransitionWhen(repo.ADMIN_STATE_USER_DETAILS, repo.ADMIN_EVENT_CLIENT_CONFIG, repo.ADMIN_STATE_USER_DETAILS, auth.Guard(repo.ADMIN_EVENT_CLIENT_CONFIG)).
+		TransitionWhen(repo.ADMIN_STATE_DEFAULT, repo.ADMIN_EVENT_BULK_CREATE, repo.ADMIN_STATE_BULK_CREATE_UPLOAD, auth.Guard(repo.ADMIN_EVENT_BULK_CREATE)).
+		Transition(repo.ADMIN_STATE_BULK_CREATE_UPLOAD, repo.ADMIN_EVENT_NEXT, repo.ADMIN_STATE_BULK_CREATE_CONFIRM).
+		Transition(repo.ADMIN_STATE_BULK_CREATE_UPLOAD, repo.ADMIN_EVENT_BACK, repo.ADMIN_STATE_DEFAULT).
+		TransitionWhen(repo.ADMIN_STATE_BULK_CREATE_CONFIRM, repo.ADMIN_EVENT_CONFIRM_BULK, repo.ADMIN_STATE_DEFAULT, auth.Guard(repo.ADMIN_EVENT_CONFIRM_BULK)).
+		Transition(repo.ADMIN_STATE_BULK_CREATE_CONFIRM, repo.ADMIN_EVENT_BACK, repo.ADMIN_STATE_DEFAULT).
+		Transition(repo.ADMIN_STATE_EXPORT_USERS, repo.ADMIN_EVENT_EXPORT_FORMAT, repo.ADMIN_STATE_EXPORT_USERS).
+		Transition(repo.ADMIN_STATE_EXPORT_USERS, repo.ADMIN_EVENT_EXPORT_COLUMN, repo.ADMIN_STATE_EXPORT_USERS).
+		TransitionWhen(repo.ADMIN_STATE_EXPORT_USERS, repo.ADMIN_EVENT_EXPORT, repo.ADMIN_STATE_DEFAULT, auth.Guard(repo.ADMIN_EVENT_EXPORT)).
+		Transition(repo.ADMIN_STATE_EXPORT_USERS, repo.ADMIN_EVENT_BACK, repo.ADMIN_STATE_DEFAULT).
+		TransitionWhen(repo.ADMIN_STATE_USER_DETAILS, repo.ADMIN_EVENT_USER_META, repo.ADMIN_STATE_USER_META_INPUT, auth.Guard(repo.ADMIN_EVENT_USER_META)).
+		Transition(repo.ADMIN_STATE_USER_META_INPUT, repo.ADMIN_EVENT_NEXT, repo.ADMIN_STATE_USER_DETAILS).
+		Transition(repo.ADMIN_STATE_USER_META_INPUT, repo.ADMIN_EVENT_BACK, repo.ADMIN_STATE_USER_DETAILS)
+
+	af.registerMenu(f)
+	af.registerLists(f)
+	af.registerCreateUser(f)
+	af.registerUserDetails(f)
+	af.registerQRCodes(f)
+	af.registerClientConfigs(f)
+	af.registerBulkCreate(f)
+	af.registerExportUsers(f)
+	af.registerUserMeta(f)
+
+	return f
+}
+
+// InputError rejects the admin's input. The handler shows its message
+// to the admin instead of a generic error, and the state does not change.
+type InputError struct {
+	Message string
+}
+
+func (e *InputError) Error() string {
+	return e.Message
+}
+
+func inputErrorf(format string, args ...any) error {
+	return &InputError{Message: fmt.Sprintf(format, args...)}
+}
+
+// tgMeta is what the message handler puts into the FSM context
+// before triggering a transition.
+type tgMeta struct {
+	bot    *bot.Bot
+	chatID int64
+	ctx    context.Context
+}
+
+func metaOf(ctx *fsm.FSMContext) tgMeta {
+	return tgMeta{
+		bot:    ctx.Meta["tgbot"].(*bot.Bot),
+		chatID: ctx.Meta["tgchat"].(int64),
+		ctx:    ctx.Meta["tgctx"].(context.Context),
+	}
+}
+
+// render shows a message that is updated in place. When the admin clicked
+// a button of the message stored under key, that message is edited,
+// otherwise a new one is sent and remembered under key.
+func (af *AdminFlow) render(ctx *fsm.FSMContext, key string, params *bot.SendMessageParams) error {
+	m := metaOf(ctx)
+
+	messageID, _ := ctx.Data[key].(int)
+	update, _ := ctx.Meta["tgmes"].(*models.Update)
+	if messageID != 0 && update != nil && update.CallbackQuery != nil &&
+		update.CallbackQuery.Message.Message != nil && update.CallbackQuery.Message.Message.ID == messageID {
+		_, err := m.bot.EditMessageText(m.ctx, &bot.EditMessageTextParams{
+			ChatID:      m.chatID,
+			MessageID:   messageID,
+			Text:        params.Text,
+			ParseMode:   params.ParseMode,
+			ReplyMarkup: params.ReplyMarkup,
+		})
+		if err == nil || strings.Contains(err.Error(), "message is not modified") {
+			return nil
+		}
+		af.logger.Error(err.Error())
+	}
+
+	params.ChatID = m.chatID
+	message, err := m.bot.SendMessage(m.ctx, params)
+	if err != nil {
+		af.logger.Error(err.Error())
+		return err
+	}
+	ctx.Data[key] = message.ID
+	return nil
+}
+
+func (af *AdminFlow) send(m tgMeta, params *bot.SendMessageParams) error {
+	params.ChatID = m.chatID
+	if _, err := m.bot.SendMessage(m.ctx, params); err != nil {
+		af.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
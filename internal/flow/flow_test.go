@@ -0,0 +1,167 @@
+package flow
+
+import (
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/luckyComet55/marzban-tg-bot/internal/preset"
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+)
+
+// limitedRepository supports only the listed operations, the way
+// a panel with an older contract does.
+type limitedRepository struct {
+	repo.UserRepository
+	supported []repo.UserOperation
+}
+
+func (r limitedRepository) Supports(operation repo.UserOperation) bool {
+	return slices.Contains(r.supported, operation)
+}
+
+var testUsers = []repo.UserData{
+	{UserCreateData: repo.UserCreateData{Username: "carol"}, Status: repo.USER_STATUS_ACTIVE, UsedTraffic: 3 << 30},
+	{UserCreateData: repo.UserCreateData{Username: "alice"}, Status: repo.USER_STATUS_DISABLED, UsedTraffic: 1 << 30},
+	{UserCreateData: repo.UserCreateData{Username: "bob"}, Status: repo.USER_STATUS_ACTIVE, UsedTraffic: 3 << 30},
+}
+
+func newTestFlow(userRepository repo.UserRepository) *AdminFlow {
+	return NewAdminFlow(userRepository, nil, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestMatchUsername(t *testing.T) {
+	tests := []struct {
+		query    string
+		username string
+		match    bool
+	}{
+		{"ali", "alice", true},
+		{"ALI", "alice", true},
+		{"ice", "Alice", true},
+		{"bob", "alice", false},
+		{"alice_*", "alice_1", true},
+		{"alice_*", "bob_alice_1", false},
+		{"a?ice", "Alice", true},
+		{"[ab]*", "bob", true},
+		{"[ab]*", "carol", false},
+	}
+	for _, tt := range tests {
+		if got := matchUsername(tt.query, tt.username); got != tt.match {
+			t.Errorf("matchUsername(%q, %q) = %t, want %t", tt.query, tt.username, got, tt.match)
+		}
+	}
+}
+
+func TestCanUsePreset(t *testing.T) {
+	plain := preset.Preset{Name: "plain", ProxyNames: []string{"vless"}}
+	limited := preset.Preset{Name: "limited", ProxyNames: []string{"vless"}, DataLimit: 1 << 30}
+	expiring := preset.Preset{Name: "expiring", ProxyNames: []string{"vless"}, Expire: "30d"}
+	multi := preset.Preset{Name: "multi", ProxyNames: []string{"vless", "vmess"}}
+
+	tests := []struct {
+		name      string
+		supported []repo.UserOperation
+		usable    []string
+	}{
+		{
+			name:      "everything supported",
+			supported: []repo.UserOperation{repo.USER_OPERATION_CREATE_LIMITS, repo.USER_OPERATION_CREATE_MULTI_PROXY},
+			usable:    []string{"plain", "limited", "expiring", "multi"},
+		},
+		{
+			name:      "no limits",
+			supported: []repo.UserOperation{repo.USER_OPERATION_CREATE_MULTI_PROXY},
+			usable:    []string{"plain", "multi"},
+		},
+		{
+			name:   "nothing supported",
+			usable: []string{"plain"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			af := newTestFlow(limitedRepository{supported: tt.supported})
+			usable := make([]string, 0)
+			for _, p := range []preset.Preset{plain, limited, expiring, multi} {
+				if af.canUsePreset(p) {
+					usable = append(usable, p.Name)
+				}
+			}
+			if !slices.Equal(usable, tt.usable) {
+				t.Errorf("usable presets = %v, want %v", usable, tt.usable)
+			}
+		})
+	}
+}
+
+func TestSortedUsers(t *testing.T) {
+	tests := []struct {
+		sort      userSort
+		usernames []string
+		complete  bool
+	}{
+		{sort: USER_SORT_NAME, usernames: []string{"alice", "bob", "carol"}},
+		{sort: USER_SORT_TRAFFIC, usernames: []string{"bob", "carol", "alice"}, complete: true},
+		{sort: USER_SORT_STATUS, usernames: []string{"bob", "carol", "alice"}, complete: true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.sort), func(t *testing.T) {
+			af := newTestFlow(repo.NewMemoryUserRepository(testUsers...))
+			cache := &userListCache{details: map[string]repo.UserData{}}
+
+			users, err := af.sortedUsers(cache, tt.sort)
+			if err != nil {
+				t.Fatal(err)
+			}
+			usernames := make([]string, 0, len(users))
+			for _, u := range users {
+				usernames = append(usernames, u.Username)
+			}
+			if !slices.Equal(usernames, tt.usernames) {
+				t.Errorf("usernames = %v, want %v", usernames, tt.usernames)
+			}
+			if cache.complete != tt.complete {
+				t.Errorf("cache complete = %t, want %t", cache.complete, tt.complete)
+			}
+		})
+	}
+}
+
+func TestFillUsersDetails(t *testing.T) {
+	userRepository := repo.NewMemoryUserRepository(testUsers...)
+	af := newTestFlow(userRepository)
+	cache := &userListCache{details: map[string]repo.UserData{}}
+
+	users, err := af.sortedUsers(cache, USER_SORT_NAME)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users = append(users, repo.UserData{UserCreateData: repo.UserCreateData{Username: "ghost"}})
+	af.fillUsersDetails(cache, users)
+
+	for _, u := range users[:3] {
+		if u.Status == "" {
+			t.Errorf("details of %s are not filled", u.Username)
+		}
+		if _, ok := cache.details[u.Username]; !ok {
+			t.Errorf("details of %s are not cached", u.Username)
+		}
+	}
+	if _, ok := cache.details["ghost"]; ok {
+		t.Error("missing user is cached")
+	}
+
+	// The cached list is reused even after a user is created on the panel.
+	if _, err := userRepository.CreateUser(repo.UserCreateData{Username: "dave"}); err != nil {
+		t.Fatal(err)
+	}
+	users, err = af.sortedUsers(cache, USER_SORT_NAME)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 3 || users[1].Status != repo.USER_STATUS_ACTIVE {
+		t.Errorf("users = %+v, want the cached alice, bob and carol", users)
+	}
+}
@@ -0,0 +1,99 @@
+package flow
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"time"
+
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/internal/usermeta"
+)
+
+var statusBadges = map[repo.UserStatus]string{
+	repo.USER_STATUS_ACTIVE:   "🟢",
+	repo.USER_STATUS_DISABLED: "🔴",
+	repo.USER_STATUS_ON_HOLD:  "⏸",
+	repo.USER_STATUS_LIMITED:  "🟠",
+	repo.USER_STATUS_EXPIRED:  "⌛",
+}
+
+// statusBadge returns the emoji of the status followed by a space,
+// or nothing when the status is unknown.
+func statusBadge(status repo.UserStatus) string {
+	if badge, ok := statusBadges[status]; ok {
+		return badge + " "
+	}
+	return ""
+}
+
+func formatTraffic(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+func formatDataLimit(limit int64) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	return formatTraffic(limit)
+}
+
+func formatExpiry(expire time.Time) string {
+	if expire.IsZero() {
+		return "never"
+	}
+	return expire.Format(time.DateOnly)
+}
+
+func formatNote(note string) string {
+	if note == "" {
+		return "none"
+	}
+	return note
+}
+
+// formatConfigUrls lists config URLs as HTML. Every URL is labelled
+// and put on its own line, so that it can be copied with a tap.
+func formatConfigUrls(configUrls []string) string {
+	if len(configUrls) == 0 {
+		return "No config URLs\n"
+	}
+
+	var list strings.Builder
+	for i, configUrl := range configUrls {
+		fmt.Fprintf(&list, "%d. %s\n<code>%s</code>\n", i+1, html.EscapeString(configUrlLabel(configUrl)), html.EscapeString(configUrl))
+	}
+	return list.String()
+}
+
+// configUrlLabel names a config URL by its protocol and the inbound
+// remark the panel puts into the URL fragment.
+func configUrlLabel(configUrl string) string {
+	u, err := url.Parse(configUrl)
+	if err != nil || u.Scheme == "" {
+		return "config"
+	}
+	label := strings.ToUpper(u.Scheme)
+	if u.Fragment != "" {
+		label += " · " + u.Fragment
+	}
+	return label
+}
+
+func formatUserMeta(meta usermeta.Meta) string {
+	tags := make([]string, 0, len(meta.Tags))
+	for _, tag := range meta.Tags {
+		tags = append(tags, "#"+tag)
+	}
+	return fmt.Sprintf("Tags: %s\nContact: %s\nLocal note: %s", formatNote(strings.Join(tags, " ")), formatNote(meta.Contact), formatNote(meta.Note))
+}
@@ -0,0 +1,324 @@
+package flow
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/luckyComet55/marzban-tg-bot/internal/keyboard"
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/internal/validation"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+)
+
+type userSort string
+
+const (
+	USER_SORT_NAME    userSort = "name"
+	USER_SORT_TRAFFIC userSort = "traffic"
+	USER_SORT_STATUS  userSort = "status"
+)
+
+var userSorts = []userSort{USER_SORT_NAME, USER_SORT_TRAFFIC, USER_SORT_STATUS}
+
+var (
+	usersPagination = keyboard.Pagination{
+		PerPage: 10,
+		Columns: 2,
+		Event:   repo.ADMIN_EVENT_PAGE,
+	}
+	proxiesPagination = keyboard.Pagination{
+		PerPage: 20,
+		Event:   repo.ADMIN_EVENT_PAGE,
+	}
+)
+
+func (af *AdminFlow) registerLists(f *fsm.FSM) {
+	f.OnTransition(af.updateListState)
+	f.OnEnter(repo.ADMIN_STATE_LIST_USERS, af.enterListUsers)
+	f.OnExit(repo.ADMIN_STATE_LIST_USERS, af.exitListUsers)
+	f.OnEnter(repo.ADMIN_STATE_LIST_PROXIES, af.enterListProxies)
+	f.OnEnter(repo.ADMIN_STATE_FIND_USER_INPUT_QUERY, af.enterFindUser)
+	f.OnExit(repo.ADMIN_STATE_FIND_USER_INPUT_QUERY, af.exitFindUser)
+}
+
+func (af *AdminFlow) enterFindUser(ctx *fsm.FSMContext) error {
+	kb := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{
+				{Text: "Cancel", CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_BACK)},
+			},
+		},
+	}
+
+	return af.send(metaOf(ctx), &bot.SendMessageParams{
+		Text:        "Input part of the username, a pattern like alice_* or a tag like #team-a",
+		ReplyMarkup: kb,
+	})
+}
+
+func (af *AdminFlow) exitFindUser(ctx *fsm.FSMContext) error {
+	if ctx.Event != repo.ADMIN_EVENT_NEXT {
+		return nil
+	}
+
+	query := strings.TrimSpace(ctx.Input.(string))
+	if query == "" {
+		return inputErrorf("Search query is empty, try again")
+	}
+	if tag, ok := strings.CutPrefix(query, "#"); ok {
+		query = "#" + strings.ToLower(tag)
+		if err := validation.Tag(query[1:]); err != nil {
+			return inputErrorf("Invalid tag: %s, try again", err)
+		}
+	} else if _, err := path.Match(query, ""); err != nil {
+		return inputErrorf("Invalid search pattern '%s', try again", query)
+	}
+
+	ctx.Data["users_filter"] = query
+	return nil
+}
+
+// matchUser matches users by a local tag for queries like #team-a
+// and by username otherwise.
+func (af *AdminFlow) matchUser(query, username string) bool {
+	if tag, ok := strings.CutPrefix(query, "#"); ok {
+		return af.userMeta.Get(username).HasTag(tag)
+	}
+	return matchUsername(query, username)
+}
+
+// matchUsername treats queries with wildcards as glob patterns
+// and plain queries as case-insensitive substrings.
+func matchUsername(query, username string) bool {
+	if strings.ContainsAny(query, "*?[") {
+		matched, _ := path.Match(strings.ToLower(query), strings.ToLower(username))
+		return matched
+	}
+	return strings.Contains(strings.ToLower(username), strings.ToLower(query))
+}
+
+// updateListState keeps the page and sort order of the lists in the FSM
+// context, so that coming back to a list opens it where the admin left it.
+func (af *AdminFlow) updateListState(from, to fsm.State, event fsm.Event, ctx *fsm.FSMContext) error {
+	if to != repo.ADMIN_STATE_LIST_USERS && to != repo.ADMIN_STATE_LIST_PROXIES {
+		return nil
+	}
+	pageKey := "users_page"
+	if to == repo.ADMIN_STATE_LIST_PROXIES {
+		pageKey = "proxies_page"
+	}
+
+	switch event {
+	case repo.ADMIN_EVENT_LIST_USERS:
+		ctx.Data["users_page"] = 0
+		ctx.Data["users_sort"] = USER_SORT_NAME
+		delete(ctx.Data, "users_filter")
+		delete(ctx.Data, "users_cache")
+	case repo.ADMIN_EVENT_NEXT:
+		ctx.Data["users_page"] = 0
+		ctx.Data["users_sort"] = USER_SORT_NAME
+		delete(ctx.Data, "users_cache")
+	case repo.ADMIN_EVENT_LIST_PROXIES:
+		ctx.Data["proxies_page"] = 0
+	case repo.ADMIN_EVENT_PAGE:
+		page, err := strconv.Atoi(ctx.Input.(string))
+		if err != nil {
+			return fmt.Errorf("invalid page '%s': %w", ctx.Input, err)
+		}
+		ctx.Data[pageKey] = page
+	case repo.ADMIN_EVENT_SORT:
+		sort := userSort(ctx.Input.(string))
+		if !slices.Contains(userSorts, sort) {
+			return fmt.Errorf("unknown sort order '%s'", sort)
+		}
+		ctx.Data["users_sort"] = sort
+		ctx.Data["users_page"] = 0
+	}
+	return nil
+}
+
+func (af *AdminFlow) enterListUsers(ctx *fsm.FSMContext) error {
+	page, _ := ctx.Data["users_page"].(int)
+	sort, ok := ctx.Data["users_sort"].(userSort)
+	if !ok {
+		sort = USER_SORT_NAME
+	}
+
+	cache, ok := ctx.Data["users_cache"].(*userListCache)
+	if !ok {
+		cache = &userListCache{details: make(map[string]repo.UserData)}
+		ctx.Data["users_cache"] = cache
+	}
+
+	users, err := af.sortedUsers(cache, sort)
+	if err != nil {
+		af.logger.Error(err.Error())
+		af.send(metaOf(ctx), &bot.SendMessageParams{
+			Text: "Unable to serve you right now, try again later",
+		})
+		return err
+	}
+
+	title := fmt.Sprintf("Total of %d users", len(users))
+	if filter, ok := ctx.Data["users_filter"].(string); ok {
+		users = slices.DeleteFunc(users, func(u repo.UserData) bool {
+			return !af.matchUser(filter, u.Username)
+		})
+		title = fmt.Sprintf("Found %d users matching '%s'", len(users), filter)
+	}
+
+	page = usersPagination.Clamp(page, len(users))
+	ctx.Data["users_page"] = page
+
+	start, end := usersPagination.Bounds(page, len(users))
+	af.fillUsersDetails(cache, users[start:end])
+
+	buttons := make([]models.InlineKeyboardButton, 0, len(users))
+	for _, u := range users {
+		buttons = append(buttons, models.InlineKeyboardButton{Text: statusBadge(u.Status) + u.Username, CallbackData: fmt.Sprintf("%s:%s", repo.ADMIN_EVENT_USER_DETAILS, u.Username)})
+	}
+
+	sortRow := make([]models.InlineKeyboardButton, 0, len(userSorts))
+	for _, s := range userSorts {
+		text := fmt.Sprintf("By %s", s)
+		if s == sort {
+			text += " ✓"
+		}
+		sortRow = append(sortRow, models.InlineKeyboardButton{Text: text, CallbackData: fmt.Sprintf("%s:%s", repo.ADMIN_EVENT_SORT, s)})
+	}
+
+	rows := [][]models.InlineKeyboardButton{sortRow}
+	rows = append(rows, usersPagination.Keyboard(buttons, page)...)
+	rows = append(rows, []models.InlineKeyboardButton{{Text: "Back", CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_BACK)}})
+
+	return af.render(ctx, "users_message", &bot.SendMessageParams{
+		Text:        fmt.Sprintf("%s, page %d of %d.\nChoose user to get all info", title, page+1, usersPagination.Pages(len(users))),
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: rows},
+	})
+}
+
+// userListCache keeps what the users list fetched, so that page and
+// sort clicks do not hit the panel again. It lives until the admin
+// leaves the list.
+type userListCache struct {
+	usernames []string
+	details   map[string]repo.UserData
+	// complete is set once details holds every listed user.
+	complete bool
+}
+
+func (af *AdminFlow) exitListUsers(ctx *fsm.FSMContext) error {
+	if ctx.Event != repo.ADMIN_EVENT_PAGE && ctx.Event != repo.ADMIN_EVENT_SORT {
+		delete(ctx.Data, "users_cache")
+	}
+	return nil
+}
+
+// sortedUsers lists users in the given order. Sorting by name needs
+// usernames only, other orders fetch the details of every user once.
+func (af *AdminFlow) sortedUsers(cache *userListCache, sort userSort) ([]repo.UserData, error) {
+	if sort != USER_SORT_NAME && !cache.complete {
+		details, err := af.userRepository.GetUsersDetails()
+		if err != nil {
+			return nil, err
+		}
+		cache.usernames = make([]string, 0, len(details))
+		for _, u := range details {
+			cache.usernames = append(cache.usernames, u.Username)
+			cache.details[u.Username] = u
+		}
+		cache.complete = true
+	}
+	if cache.usernames == nil {
+		shortUsers, err := af.userRepository.GetUsers()
+		if err != nil {
+			return nil, err
+		}
+		cache.usernames = make([]string, 0, len(shortUsers))
+		for _, u := range shortUsers {
+			cache.usernames = append(cache.usernames, u.Username)
+		}
+	}
+
+	users := make([]repo.UserData, 0, len(cache.usernames))
+	for _, username := range cache.usernames {
+		u, ok := cache.details[username]
+		if !ok {
+			u = repo.UserData{UserCreateData: repo.UserCreateData{Username: username}}
+		}
+		users = append(users, u)
+	}
+	slices.SortFunc(users, func(a, b repo.UserData) int {
+		if sort == USER_SORT_NAME {
+			return strings.Compare(a.Username, b.Username)
+		}
+		if sort == USER_SORT_TRAFFIC {
+			if c := cmp.Compare(b.UsedTraffic, a.UsedTraffic); c != 0 {
+				return c
+			}
+		} else if c := strings.Compare(string(a.Status), string(b.Status)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Username, b.Username)
+	})
+	return users, nil
+}
+
+// fillUsersDetails fetches details of the users listed by name only,
+// so that the shown page can carry status badges.
+func (af *AdminFlow) fillUsersDetails(cache *userListCache, users []repo.UserData) {
+	for i, u := range users {
+		if u.Status != "" {
+			continue
+		}
+		details, err := af.userRepository.GetUser(u.Username)
+		if err != nil {
+			af.logger.Error(err.Error())
+			continue
+		}
+		users[i] = details
+		cache.details[u.Username] = details
+	}
+}
+
+func (af *AdminFlow) enterListProxies(ctx *fsm.FSMContext) error {
+	page, _ := ctx.Data["proxies_page"].(int)
+
+	proxies, err := af.proxyRepository.ListProxies()
+	if err != nil {
+		af.logger.Error(err.Error())
+		af.send(metaOf(ctx), &bot.SendMessageParams{
+			Text: "Unable to serve you right now, try again later",
+		})
+		return err
+	}
+
+	page = proxiesPagination.Clamp(page, len(proxies))
+	ctx.Data["proxies_page"] = page
+	start, end := proxiesPagination.Bounds(page, len(proxies))
+
+	proxyMessage := fmt.Sprintf("Total of %d proxies, page %d of %d:\n", len(proxies), page+1, proxiesPagination.Pages(len(proxies)))
+	for _, p := range proxies[start:end] {
+		proxyMessage += fmt.Sprintf("- %s (%s)\n", html.EscapeString(p.ProxyName), p.Protocol)
+	}
+
+	rows := make([][]models.InlineKeyboardButton, 0, 2)
+	if navigation := proxiesPagination.Navigation(page, len(proxies)); len(navigation) > 0 {
+		rows = append(rows, navigation)
+	}
+	rows = append(rows, []models.InlineKeyboardButton{{Text: "Back", CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_BACK)}})
+
+	return af.render(ctx, "proxies_message", &bot.SendMessageParams{
+		Text:        proxyMessage,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: rows},
+	})
+}
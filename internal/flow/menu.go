@@ -0,0 +1,61 @@
+package flow
+
+import (
+	"fmt"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/luckyComet55/marzban-tg-bot/internal/auth"
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+)
+
+type menuItem struct {
+	text  string
+	event fsm.Event
+}
+
+var menu = [][]menuItem{
+	{
+		{"List users", repo.ADMIN_EVENT_LIST_USERS},
+		{"List proxies", repo.ADMIN_EVENT_LIST_PROXIES},
+	},
+	{
+		{"Find user", repo.ADMIN_EVENT_FIND_USER},
+	},
+	{
+		{"Create user", repo.ADMIN_EVENT_CREATE_USER},
+		{"Bulk create", repo.ADMIN_EVENT_BULK_CREATE},
+	},
+}
+
+func (af *AdminFlow) registerMenu(f *fsm.FSM) {
+	f.OnEnter(repo.ADMIN_STATE_DEFAULT, af.enterMenu)
+}
+
+func (af *AdminFlow) enterMenu(ctx *fsm.FSMContext) error {
+	m := metaOf(ctx)
+	role, _ := ctx.Meta["tgrole"].(auth.Role)
+
+	kb := &models.InlineKeyboardMarkup{
+		InlineKeyboard: make([][]models.InlineKeyboardButton, 0, len(menu)),
+	}
+	for _, menuRow := range menu {
+		row := make([]models.InlineKeyboardButton, 0, len(menuRow))
+		for _, item := range menuRow {
+			if auth.Can(role, item.event) {
+				row = append(row, models.InlineKeyboardButton{Text: item.text, CallbackData: fmt.Sprintf("%s:", item.event)})
+			}
+		}
+		if len(row) > 0 {
+			kb.InlineKeyboard = append(kb.InlineKeyboard, row)
+		}
+	}
+
+	af.send(m, &bot.SendMessageParams{
+		Text:        "Select action",
+		ReplyMarkup: kb,
+	})
+	return nil
+}
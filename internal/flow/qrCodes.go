@@ -0,0 +1,60 @@
+package flow
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	qrcode "github.com/skip2/go-qrcode"
+
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+)
+
+const qrCodeSize = 512
+
+func (af *AdminFlow) registerQRCodes(f *fsm.FSM) {
+	f.OnTransition(af.sendQRCodes)
+}
+
+// sendQRCodes sends a QR code photo for every config URL of the user,
+// so that it can be scanned by a client app right from the chat.
+func (af *AdminFlow) sendQRCodes(from, to fsm.State, event fsm.Event, ctx *fsm.FSMContext) error {
+	if event != repo.ADMIN_EVENT_QR_CODES {
+		return nil
+	}
+	m := metaOf(ctx)
+
+	username := ctx.Input.(string)
+	user, err := af.userRepository.GetUser(username)
+	if err != nil {
+		af.logger.Error(err.Error())
+		if errors.Is(err, repo.ErrUserNotFound) {
+			return inputErrorf("User %s not found", username)
+		}
+		return inputErrorf("Could not get config URLs of %s, try again later", username)
+	}
+	if len(user.ConfigUrls) == 0 {
+		return inputErrorf("User %s has no config URLs", username)
+	}
+
+	for i, configUrl := range user.ConfigUrls {
+		png, err := qrcode.Encode(configUrl, qrcode.Medium, qrCodeSize)
+		if err != nil {
+			af.logger.Error(err.Error())
+			return inputErrorf("Could not make a QR code for config URL %d of %s", i+1, username)
+		}
+
+		if _, err := m.bot.SendPhoto(m.ctx, &bot.SendPhotoParams{
+			ChatID:  m.chatID,
+			Photo:   &models.InputFileUpload{Filename: fmt.Sprintf("%s_%d.png", username, i+1), Data: bytes.NewReader(png)},
+			Caption: fmt.Sprintf("%s\n%s", username, configUrlLabel(configUrl)),
+		}); err != nil {
+			af.logger.Error(err.Error())
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package flow
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/luckyComet55/marzban-tg-bot/internal/auth"
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/internal/usermeta"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+)
+
+func (af *AdminFlow) registerUserDetails(f *fsm.FSM) {
+	f.OnEnter(repo.ADMIN_STATE_USER_DETAILS, af.enterUserDetails)
+}
+
+func (af *AdminFlow) enterUserDetails(ctx *fsm.FSMContext) error {
+	m := metaOf(ctx)
+	username, _ := ctx.Data["details_username"].(string)
+	if ctx.Event == repo.ADMIN_EVENT_USER_DETAILS {
+		username = ctx.Input.(string)
+	}
+
+	user, err := af.userRepository.GetUser(username)
+	if err != nil {
+		af.logger.Error(err.Error())
+		text := "Unable to serve you right now, try again later"
+		if errors.Is(err, repo.ErrUserNotFound) {
+			text = fmt.Sprintf("User %s not found", username)
+		}
+		af.send(m, &bot.SendMessageParams{
+			Text: text,
+		})
+		return err
+	}
+	ctx.Data["details_username"] = user.Username
+
+	role, _ := ctx.Meta["tgrole"].(auth.Role)
+	kb := &models.InlineKeyboardMarkup{
+		InlineKeyboard: make([][]models.InlineKeyboardButton, 0, 3),
+	}
+	if len(user.ConfigUrls) > 0 && auth.Can(role, repo.ADMIN_EVENT_QR_CODES) {
+		kb.InlineKeyboard = append(kb.InlineKeyboard, []models.InlineKeyboardButton{
+			{Text: "QR codes", CallbackData: fmt.Sprintf("%s:%s", repo.ADMIN_EVENT_QR_CODES, user.Username)},
+		})
+	}
+	if len(user.ConfigUrls) > 0 && auth.Can(role, repo.ADMIN_EVENT_CLIENT_CONFIG) {
+		row := make([]models.InlineKeyboardButton, 0, len(clientConfigs))
+		for _, c := range clientConfigs {
+			row = append(row, models.InlineKeyboardButton{Text: c.name, CallbackData: fmt.Sprintf("%s:%s", repo.ADMIN_EVENT_CLIENT_CONFIG, c.id)})
+		}
+		kb.InlineKeyboard = append(kb.InlineKeyboard, row)
+	}
+	if auth.Can(role, repo.ADMIN_EVENT_USER_META) {
+		kb.InlineKeyboard = append(kb.InlineKeyboard, []models.InlineKeyboardButton{
+			{Text: "Notes & tags", CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_USER_META)},
+		})
+	}
+	kb.InlineKeyboard = append(kb.InlineKeyboard, []models.InlineKeyboardButton{
+		{Text: "Back", CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_BACK)},
+	})
+
+	return af.render(ctx, "details_message", &bot.SendMessageParams{
+		Text:        userCard(user, af.userMeta.Get(user.Username)),
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: kb,
+	})
+}
+
+func userCard(user repo.UserData, meta usermeta.Meta) string {
+	var card strings.Builder
+
+	fmt.Fprintf(&card, "<b>%s</b>\n", html.EscapeString(user.Username))
+	fmt.Fprintf(&card, "Status: %s%s\n", statusBadge(user.Status), html.EscapeString(string(user.Status)))
+	fmt.Fprintf(&card, "Used traffic: %s\n", formatTraffic(user.UsedTraffic))
+
+	proxies := make([]string, 0, len(user.Proxies))
+	for _, p := range user.Proxies {
+		proxies = append(proxies, fmt.Sprintf("%s (%s)", html.EscapeString(p.ProxyName), p.Protocol))
+	}
+	fmt.Fprintf(&card, "Proxies: %s\n", strings.Join(proxies, ", "))
+	if user.Note != "" {
+		fmt.Fprintf(&card, "Note: %s\n", html.EscapeString(user.Note))
+	}
+
+	if !meta.IsZero() {
+		fmt.Fprintf(&card, "\n%s\n", html.EscapeString(formatUserMeta(meta)))
+	}
+
+	card.WriteString("\nConfig URLs:\n")
+	card.WriteString(formatConfigUrls(user.ConfigUrls))
+	return card.String()
+}
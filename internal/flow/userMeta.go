@@ -0,0 +1,84 @@
+package flow
+
+import (
+	"fmt"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/internal/usermeta"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+)
+
+const userMetaPrompt = "Send lines like\ntags: team-a, paid\ncontact: @alice\nnote: pays in cash\nOnly the given fields change, - clears a field"
+
+func (af *AdminFlow) registerUserMeta(f *fsm.FSM) {
+	f.OnEnter(repo.ADMIN_STATE_USER_META_INPUT, af.enterUserMetaInput)
+	f.OnExit(repo.ADMIN_STATE_USER_META_INPUT, af.exitUserMetaInput)
+	f.OnEnter(repo.ADMIN_STATE_CREATE_USER_META, af.enterCreateUserMeta)
+	f.OnExit(repo.ADMIN_STATE_CREATE_USER_META, af.exitCreateUserMeta)
+}
+
+func (af *AdminFlow) enterUserMetaInput(ctx *fsm.FSMContext) error {
+	username := ctx.Data["details_username"].(string)
+
+	kb := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{
+				{Text: "Cancel", CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_BACK)},
+			},
+		},
+	}
+
+	return af.send(metaOf(ctx), &bot.SendMessageParams{
+		Text:        fmt.Sprintf("%s\n\n%s", formatUserMeta(af.userMeta.Get(username)), userMetaPrompt),
+		ReplyMarkup: kb,
+	})
+}
+
+func (af *AdminFlow) exitUserMetaInput(ctx *fsm.FSMContext) error {
+	if ctx.Event != repo.ADMIN_EVENT_NEXT {
+		return nil
+	}
+
+	username := ctx.Data["details_username"].(string)
+	meta, err := usermeta.ParseInput(ctx.Input.(string), af.userMeta.Get(username))
+	if err != nil {
+		return inputErrorf("%s, try again", err)
+	}
+	if err := af.userMeta.Set(username, meta); err != nil {
+		af.logger.Error(err.Error())
+		return inputErrorf("Could not save notes and tags of %s, try again later", username)
+	}
+	return nil
+}
+
+func (af *AdminFlow) enterCreateUserMeta(ctx *fsm.FSMContext) error {
+	kb := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{
+				{Text: "Skip", CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_SKIP)},
+				{Text: "Cancel", CallbackData: fmt.Sprintf("%s:", repo.ADMIN_EVENT_CANCEL)},
+			},
+		},
+	}
+
+	return af.send(metaOf(ctx), &bot.SendMessageParams{
+		Text:        "Add notes and tags kept by the bot or press Skip.\n" + userMetaPrompt,
+		ReplyMarkup: kb,
+	})
+}
+
+func (af *AdminFlow) exitCreateUserMeta(ctx *fsm.FSMContext) error {
+	if ctx.Event != repo.ADMIN_EVENT_NEXT {
+		return nil
+	}
+
+	meta, err := usermeta.ParseInput(ctx.Input.(string), usermeta.Meta{})
+	if err != nil {
+		return inputErrorf("%s, try again", err)
+	}
+	ctx.Data["user_meta"] = meta
+	return nil
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -9,6 +10,8 @@ import (
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 
+	"github.com/luckyComet55/marzban-tg-bot/internal/auth"
+	"github.com/luckyComet55/marzban-tg-bot/internal/flow"
 	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
 	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
 )
@@ -102,6 +105,17 @@ func (mh *MessageHandler) HandleStart(ctx context.Context, b *bot.Bot, update *m
 		return
 	}
 
+	if err := mh.adminRepository.SetAdminMeta(adminID, "tgrole", auth.RoleFromContext(ctx)); err != nil {
+		mh.logger.Error(err.Error())
+		if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			Text:   "Unable to serve you, try again later",
+			ChatID: chatID,
+		}); err != nil {
+			mh.logger.Error(err.Error())
+		}
+		return
+	}
+
 	if err := mh.adminRepository.SetAdminState(adminID, repo.ADMIN_STATE_DEFAULT); err != nil {
 		mh.logger.Error(err.Error())
 		if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
@@ -184,14 +198,14 @@ func (mh *MessageHandler) HandleUpdate(ctx context.Context, b *bot.Bot, update *
 	}
 
 	var adminInput, transitionName string
-	transitionName = "next"
+	transitionName = string(repo.ADMIN_EVENT_NEXT)
 	if update.Message != nil {
 		adminInput = update.Message.Text
 	} else if update.CallbackQuery != nil {
-		queryData := strings.Split(update.CallbackQuery.Data, ":")
-		adminInput = queryData[1]
-		if queryData[0] != "" {
-			transitionName = queryData[0]
+		queryName, queryInput, _ := strings.Cut(update.CallbackQuery.Data, ":")
+		adminInput = queryInput
+		if queryName != "" {
+			transitionName = queryName
 		}
 	} else {
 		adminInput = ""
@@ -241,9 +255,84 @@ func (mh *MessageHandler) HandleUpdate(ctx context.Context, b *bot.Bot, update *
 		return
 	}
 
+	if err := mh.adminRepository.SetAdminMeta(adminID, "tgrole", auth.RoleFromContext(ctx)); err != nil {
+		mh.logger.Error(err.Error())
+		if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			Text:   "Unable to serve you, try again later",
+			ChatID: chatID,
+		}); err != nil {
+			mh.logger.Error(err.Error())
+		}
+		return
+	}
+
 	mh.logger.Debug("user input is", "input", adminInput)
 
 	if err := mh.adminRepository.TriggerAdminTransition(adminID, fsm.Event(transitionName), adminInput); err != nil {
+		text := "Unable to serve you, try again later"
+		var inputErr *flow.InputError
+		if errors.As(err, &inputErr) {
+			mh.logger.Debug(err.Error())
+			text = inputErr.Message
+		} else {
+			mh.logger.Error(err.Error())
+		}
+		if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			Text:   text,
+			ChatID: chatID,
+		}); err != nil {
+			mh.logger.Error(err.Error())
+		}
+	}
+}
+
+// HandleExport opens the export screen whatever state the admin is in.
+func (mh *MessageHandler) HandleExport(ctx context.Context, b *bot.Bot, update *models.Update) {
+	adminID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+
+	exists, err := mh.adminRepository.CheckAdminExists(adminID)
+	if err != nil {
+		mh.logger.Error(err.Error())
+		if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			Text:   "Unable to serve you right now, try again later",
+			ChatID: chatID,
+		}); err != nil {
+			mh.logger.Error(err.Error())
+		}
+		return
+	}
+	if !exists {
+		if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			Text:   "To start using bot enter /start",
+			ChatID: chatID,
+		}); err != nil {
+			mh.logger.Error(err.Error())
+		}
+		return
+	}
+
+	meta := map[string]any{
+		"tgbot":  b,
+		"tgmes":  update,
+		"tgchat": chatID,
+		"tgctx":  ctx,
+		"tgrole": auth.RoleFromContext(ctx),
+	}
+	for key, value := range meta {
+		if err := mh.adminRepository.SetAdminMeta(adminID, key, value); err != nil {
+			mh.logger.Error(err.Error())
+			if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+				Text:   "Unable to serve you, try again later",
+				ChatID: chatID,
+			}); err != nil {
+				mh.logger.Error(err.Error())
+			}
+			return
+		}
+	}
+
+	if err := mh.adminRepository.SetAdminState(adminID, repo.ADMIN_STATE_EXPORT_USERS); err != nil {
 		mh.logger.Error(err.Error())
 		if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
 			Text:   "Unable to serve you, try again later",
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/luckyComet55/marzban-tg-bot/internal/preset"
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/internal/validation"
+)
+
+type TemplateHandler struct {
+	logger          *slog.Logger
+	presets         *preset.Store
+	userRepository  repo.UserRepository
+	proxyRepository repo.ProxyRepository
+}
+
+func NewTemplateHandler(presets *preset.Store, userRepo repo.UserRepository, proxyRepo repo.ProxyRepository, logger *slog.Logger) *TemplateHandler {
+	return &TemplateHandler{
+		logger:          logger,
+		presets:         presets,
+		userRepository:  userRepo,
+		proxyRepository: proxyRepo,
+	}
+}
+
+// HandleTemplates lists templates, or adds and removes them
+// with the add and remove subcommands.
+func (th *TemplateHandler) HandleTemplates(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	args := strings.Fields(update.Message.Text)[1:]
+	switch {
+	case len(args) == 0:
+		th.list(ctx, b, chatID)
+	case args[0] == "add" && len(args) == 5:
+		th.add(ctx, b, update, args[1:])
+	case args[0] == "remove" && len(args) == 2:
+		th.remove(ctx, b, update, args[1])
+	default:
+		usageFormat := "Usage:\n%[1]s\n%[1]s add <name> <proxy;proxy> <data limit> <expiry>\n%[1]s remove <name>\n\nFor example: %[1]s add trial VLESS 5GB 7d"
+		th.reply(ctx, b, chatID, fmt.Sprintf(usageFormat, repo.ADMIN_COMMAND_TEMPLATES))
+	}
+}
+
+func (th *TemplateHandler) list(ctx context.Context, b *bot.Bot, chatID int64) {
+	presets := th.presets.List()
+	if len(presets) == 0 {
+		th.reply(ctx, b, chatID, fmt.Sprintf("No templates yet. Add one with %s add", repo.ADMIN_COMMAND_TEMPLATES))
+		return
+	}
+
+	templatesMessage := fmt.Sprintf("Total of %d templates:\n", len(presets))
+	for _, p := range presets {
+		templatesMessage += fmt.Sprintf("- %s: %s\n", p.Name, p.Summary())
+	}
+	th.reply(ctx, b, chatID, templatesMessage)
+}
+
+func (th *TemplateHandler) add(ctx context.Context, b *bot.Bot, update *models.Update, args []string) {
+	chatID := update.Message.Chat.ID
+
+	p := preset.Preset{Name: args[0]}
+	if err := preset.ValidateName(p.Name); err != nil {
+		th.reply(ctx, b, chatID, err.Error())
+		return
+	}
+
+	proxies, err := th.proxyRepository.ListProxies()
+	if err != nil {
+		th.logger.Error(err.Error())
+		th.reply(ctx, b, chatID, "Unable to serve you right now, try again later")
+		return
+	}
+	for _, name := range strings.Split(args[1], ";") {
+		if !slices.ContainsFunc(proxies, func(p repo.ProxyData) bool { return p.ProxyName == name }) {
+			th.reply(ctx, b, chatID, fmt.Sprintf("Unknown proxy '%s'", name))
+			return
+		}
+		if !slices.Contains(p.ProxyNames, name) {
+			p.ProxyNames = append(p.ProxyNames, name)
+		}
+	}
+
+	if p.DataLimit, err = validation.DataLimit(args[2]); err != nil {
+		th.reply(ctx, b, chatID, err.Error())
+		return
+	}
+	expire, err := validation.Expiry(args[3], time.Now())
+	if err != nil {
+		th.reply(ctx, b, chatID, err.Error())
+		return
+	}
+	if !expire.IsZero() {
+		p.Expire = strings.ToLower(args[3])
+	}
+
+	if (p.DataLimit != 0 || !expire.IsZero()) && !th.userRepository.Supports(repo.USER_OPERATION_CREATE_LIMITS) {
+		th.reply(ctx, b, chatID, "The panel does not support data limit and expiry on creation yet, use unlimited and never")
+		return
+	}
+	if len(p.ProxyNames) > 1 && !th.userRepository.Supports(repo.USER_OPERATION_CREATE_MULTI_PROXY) {
+		th.reply(ctx, b, chatID, "The panel supports a single proxy per user on creation")
+		return
+	}
+
+	if err := th.presets.Save(p); err != nil {
+		th.logger.Error(err.Error())
+		th.reply(ctx, b, chatID, "Unable to save the template, try again later")
+		return
+	}
+
+	th.logger.Info(fmt.Sprintf("user %d saved template %s", update.Message.From.ID, p.Name))
+	th.reply(ctx, b, chatID, fmt.Sprintf("Template %s is saved", p.Name))
+}
+
+func (th *TemplateHandler) remove(ctx context.Context, b *bot.Bot, update *models.Update, name string) {
+	chatID := update.Message.Chat.ID
+
+	if err := th.presets.Remove(name); err != nil {
+		if errors.Is(err, preset.ErrPresetNotFound) {
+			th.reply(ctx, b, chatID, fmt.Sprintf("Template %s does not exist", name))
+			return
+		}
+		th.logger.Error(err.Error())
+		th.reply(ctx, b, chatID, "Unable to remove the template, try again later")
+		return
+	}
+
+	th.logger.Info(fmt.Sprintf("user %d removed template %s", update.Message.From.ID, name))
+	th.reply(ctx, b, chatID, fmt.Sprintf("Template %s is removed", name))
+}
+
+func (th *TemplateHandler) reply(ctx context.Context, b *bot.Bot, chatID int64, text string) {
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		Text:   text,
+		ChatID: chatID,
+	}); err != nil {
+		th.logger.Error(err.Error())
+	}
+}
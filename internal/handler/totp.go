@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	qrcode "github.com/skip2/go-qrcode"
+
+	"github.com/luckyComet55/marzban-tg-bot/internal/auth"
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/totp"
+)
+
+// enrollMessageTTL bounds how long the QR code with the secret
+// stays in the chat when the enrollment is never confirmed.
+const enrollMessageTTL = 10 * time.Minute
+
+// TOTPHandler enrolls and disables TOTP. The enrollment QR code carries
+// the secret, so its message is deleted once the enrollment is confirmed
+// or after enrollMessageTTL. The message is kept in the TOTP store,
+// so a restart does not leave it behind.
+type TOTPHandler struct {
+	logger  *slog.Logger
+	secrets *auth.TOTPStore
+	mu      sync.Mutex
+}
+
+func NewTOTPHandler(secrets *auth.TOTPStore, logger *slog.Logger) *TOTPHandler {
+	return &TOTPHandler{
+		logger:  logger,
+		secrets: secrets,
+	}
+}
+
+// ScheduleEnrollMessages resumes the deletion of QR code messages
+// that were sent before the restart.
+func (th *TOTPHandler) ScheduleEnrollMessages(ctx context.Context, b *bot.Bot) {
+	for adminID, message := range th.secrets.EnrollMessages() {
+		th.scheduleEnrollMessage(ctx, b, adminID, message)
+	}
+}
+
+func (th *TOTPHandler) HandleEnroll(ctx context.Context, b *bot.Bot, update *models.Update) {
+	adminID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+
+	if th.secrets.IsEnrolled(adminID) {
+		th.reply(ctx, b, chatID, fmt.Sprintf("TOTP is already enrolled. Use %s <code> to disable it first", repo.ADMIN_COMMAND_TOTP_DISABLE))
+		return
+	}
+
+	me, err := b.GetMe(ctx)
+	if err != nil {
+		th.logger.Error(err.Error())
+		th.reply(ctx, b, chatID, "Unable to enroll TOTP, try again later")
+		return
+	}
+
+	// A repeated enrollment replaces the secret, so the old QR code is useless.
+	th.deleteEnrollMessage(ctx, b, adminID)
+
+	secret, err := th.secrets.Enroll(adminID)
+	if err != nil {
+		th.logger.Error(err.Error())
+		th.reply(ctx, b, chatID, "Unable to enroll TOTP, try again later")
+		return
+	}
+
+	account := update.Message.From.Username
+	if account == "" {
+		account = fmt.Sprint(adminID)
+	}
+
+	png, err := qrcode.Encode(totp.KeyURI(me.Username, account, secret), qrcode.Medium, 256)
+	if err != nil {
+		th.logger.Error(err.Error())
+		th.reply(ctx, b, chatID, "Unable to enroll TOTP, try again later")
+		return
+	}
+
+	enrollFormat := "Scan the QR code with your authenticator app, then confirm enrollment with %s <code>. The QR code is deleted after that or in %d minutes"
+	message, err := b.SendPhoto(ctx, &bot.SendPhotoParams{
+		ChatID:  chatID,
+		Photo:   &models.InputFileUpload{Filename: "totp.png", Data: bytes.NewReader(png)},
+		Caption: fmt.Sprintf(enrollFormat, repo.ADMIN_COMMAND_TOTP_CONFIRM, int(enrollMessageTTL.Minutes())),
+	})
+	if err != nil {
+		th.logger.Error(err.Error())
+		return
+	}
+
+	enrollMessage := auth.EnrollMessage{ChatID: chatID, MessageID: message.ID, SentAt: time.Now()}
+	if err := th.secrets.SetEnrollMessage(adminID, enrollMessage); err != nil {
+		th.logger.Error(err.Error())
+	}
+	th.scheduleEnrollMessage(ctx, b, adminID, enrollMessage)
+}
+
+func (th *TOTPHandler) HandleConfirm(ctx context.Context, b *bot.Bot, update *models.Update) {
+	adminID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+
+	if th.secrets.IsEnrolled(adminID) {
+		th.reply(ctx, b, chatID, "TOTP is already enrolled")
+		return
+	}
+
+	_, code, _ := strings.Cut(update.Message.Text, " ")
+	if err := th.secrets.Verify(adminID, code); err != nil {
+		th.replyVerifyError(ctx, b, chatID, err)
+		return
+	}
+
+	th.logger.Info(fmt.Sprintf("user %d enrolled TOTP", adminID))
+	th.deleteEnrollMessage(ctx, b, adminID)
+	th.reply(ctx, b, chatID, "TOTP is enrolled. Sensitive actions now require a code")
+}
+
+func (th *TOTPHandler) HandleDisable(ctx context.Context, b *bot.Bot, update *models.Update) {
+	adminID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+
+	if !th.secrets.IsEnrolled(adminID) {
+		th.reply(ctx, b, chatID, "TOTP is not enrolled")
+		return
+	}
+
+	_, code, _ := strings.Cut(update.Message.Text, " ")
+	if err := th.secrets.Verify(adminID, code); err != nil {
+		th.replyVerifyError(ctx, b, chatID, err)
+		return
+	}
+
+	if err := th.secrets.Remove(adminID); err != nil {
+		th.logger.Error(err.Error())
+		th.reply(ctx, b, chatID, "Unable to disable TOTP, try again later")
+		return
+	}
+
+	th.logger.Info(fmt.Sprintf("user %d disabled TOTP", adminID))
+	th.reply(ctx, b, chatID, "TOTP is disabled")
+}
+
+func (th *TOTPHandler) replyVerifyError(ctx context.Context, b *bot.Bot, chatID int64, err error) {
+	switch {
+	case errors.Is(err, auth.ErrTOTPNotEnrolled):
+		th.reply(ctx, b, chatID, fmt.Sprintf("Start with %s first", repo.ADMIN_COMMAND_TOTP_ENROLL))
+	case errors.Is(err, auth.ErrTOTPInvalidCode):
+		th.reply(ctx, b, chatID, "Wrong code, try again")
+	default:
+		th.logger.Error(err.Error())
+		th.reply(ctx, b, chatID, "Unable to verify the code, try again later")
+	}
+}
+
+// scheduleEnrollMessage deletes the message after enrollMessageTTL,
+// unless it was already deleted or replaced by then.
+func (th *TOTPHandler) scheduleEnrollMessage(ctx context.Context, b *bot.Bot, adminID int64, message auth.EnrollMessage) {
+	ctx = context.WithoutCancel(ctx)
+	time.AfterFunc(time.Until(message.SentAt.Add(enrollMessageTTL)), func() {
+		th.mu.Lock()
+		defer th.mu.Unlock()
+
+		current, ok := th.secrets.EnrollMessages()[adminID]
+		if !ok || current.MessageID != message.MessageID {
+			return
+		}
+		th.takeAndDelete(ctx, b, adminID)
+	})
+}
+
+func (th *TOTPHandler) deleteEnrollMessage(ctx context.Context, b *bot.Bot, adminID int64) {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+
+	th.takeAndDelete(ctx, b, adminID)
+}
+
+func (th *TOTPHandler) takeAndDelete(ctx context.Context, b *bot.Bot, adminID int64) {
+	message, ok, err := th.secrets.TakeEnrollMessage(adminID)
+	if err != nil {
+		th.logger.Error(err.Error())
+	}
+	if !ok {
+		return
+	}
+
+	if _, err := b.DeleteMessage(ctx, &bot.DeleteMessageParams{
+		ChatID:    message.ChatID,
+		MessageID: message.MessageID,
+	}); err != nil {
+		th.logger.Error(err.Error())
+	}
+}
+
+func (th *TOTPHandler) reply(ctx context.Context, b *bot.Bot, chatID int64, text string) {
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		Text:   text,
+		ChatID: chatID,
+	}); err != nil {
+		th.logger.Error(err.Error())
+	}
+}
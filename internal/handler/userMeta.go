@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/internal/usermeta"
+	"github.com/luckyComet55/marzban-tg-bot/internal/validation"
+)
+
+type UserMetaHandler struct {
+	logger   *slog.Logger
+	userMeta *usermeta.Store
+}
+
+func NewUserMetaHandler(userMeta *usermeta.Store, logger *slog.Logger) *UserMetaHandler {
+	return &UserMetaHandler{
+		logger:   logger,
+		userMeta: userMeta,
+	}
+}
+
+// HandleRename moves notes, contact and tags to the new username
+// after a user is renamed on the panel.
+func (mh *UserMetaHandler) HandleRename(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	args := strings.Fields(update.Message.Text)[1:]
+	if len(args) != 2 {
+		mh.reply(ctx, b, chatID, fmt.Sprintf("Usage: %s <old username> <new username>", repo.ADMIN_COMMAND_META_RENAME))
+		return
+	}
+	if err := validation.Username(args[1]); err != nil {
+		mh.reply(ctx, b, chatID, fmt.Sprintf("Invalid username: %s", err))
+		return
+	}
+
+	if err := mh.userMeta.Rename(args[0], args[1]); err != nil {
+		if errors.Is(err, usermeta.ErrMetaNotFound) || errors.Is(err, usermeta.ErrMetaExists) {
+			mh.reply(ctx, b, chatID, err.Error())
+			return
+		}
+		mh.logger.Error(err.Error())
+		mh.reply(ctx, b, chatID, "Unable to move notes and tags, try again later")
+		return
+	}
+
+	mh.logger.Info(fmt.Sprintf("user %d moved notes and tags of %s to %s", update.Message.From.ID, args[0], args[1]))
+	mh.reply(ctx, b, chatID, fmt.Sprintf("Notes and tags of %s now belong to %s", args[0], args[1]))
+}
+
+func (mh *UserMetaHandler) reply(ctx context.Context, b *bot.Bot, chatID int64, text string) {
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		Text:   text,
+		ChatID: chatID,
+	}); err != nil {
+		mh.logger.Error(err.Error())
+	}
+}
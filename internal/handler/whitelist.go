@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/luckyComet55/marzban-tg-bot/internal/auth"
+	"github.com/luckyComet55/marzban-tg-bot/internal/middleware"
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+)
+
+type WhitelistHandler struct {
+	logger          *slog.Logger
+	whitelist       *middleware.WhitelistMiddleware
+	invites         *auth.InviteStore
+	intruders       *middleware.IntruderTracker
+	adminRepository repo.AdminRepository
+}
+
+func NewWhitelistHandler(whitelist *middleware.WhitelistMiddleware, invites *auth.InviteStore, intruders *middleware.IntruderTracker, adminRepo repo.AdminRepository, logger *slog.Logger) *WhitelistHandler {
+	return &WhitelistHandler{
+		logger:          logger,
+		whitelist:       whitelist,
+		invites:         invites,
+		intruders:       intruders,
+		adminRepository: adminRepo,
+	}
+}
+
+func (wh *WhitelistHandler) HandleAdmins(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	users := wh.whitelist.ListUsers()
+	userIDs := make([]int64, 0, len(users))
+	for userID := range users {
+		userIDs = append(userIDs, userID)
+	}
+	slices.Sort(userIDs)
+
+	adminsMessage := fmt.Sprintf("Total of %d admins:\n", len(userIDs))
+	for _, userID := range userIDs {
+		adminsMessage += fmt.Sprintf("- %d: %s\n", userID, users[userID])
+	}
+
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		Text:   adminsMessage,
+		ChatID: chatID,
+	}); err != nil {
+		wh.logger.Error(err.Error())
+	}
+}
+
+func (wh *WhitelistHandler) HandleAdminAdd(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	args := strings.Fields(update.Message.Text)[1:]
+	if len(args) < 1 || len(args) > 2 {
+		wh.reply(ctx, b, chatID, fmt.Sprintf("Usage: %s <id> [viewer|operator|owner]", repo.ADMIN_COMMAND_ADMIN_ADD))
+		return
+	}
+
+	userID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		wh.reply(ctx, b, chatID, fmt.Sprintf("'%s' is not a valid Telegram user ID", args[0]))
+		return
+	}
+
+	role := auth.ROLE_VIEWER
+	if len(args) == 2 {
+		if role, err = auth.ParseRole(args[1]); err != nil {
+			wh.reply(ctx, b, chatID, err.Error())
+			return
+		}
+	}
+
+	if err := wh.whitelist.SetUserRole(userID, role); err != nil {
+		if errors.Is(err, middleware.ErrLastOwner) {
+			wh.reply(ctx, b, chatID, fmt.Sprintf("User %d is the last owner. Add another owner first", userID))
+			return
+		}
+		wh.logger.Error(err.Error())
+		wh.reply(ctx, b, chatID, "Unable to update the whitelist, try again later")
+		return
+	}
+
+	wh.logger.Info(fmt.Sprintf("user %d added user %d to the whitelist as %s", update.Message.From.ID, userID, role))
+	wh.reply(ctx, b, chatID, fmt.Sprintf("User %d is now %s", userID, role))
+}
+
+func (wh *WhitelistHandler) HandleAdminRemove(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	args := strings.Fields(update.Message.Text)[1:]
+	if len(args) != 1 {
+		wh.reply(ctx, b, chatID, fmt.Sprintf("Usage: %s <id>", repo.ADMIN_COMMAND_ADMIN_REMOVE))
+		return
+	}
+
+	userID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		wh.reply(ctx, b, chatID, fmt.Sprintf("'%s' is not a valid Telegram user ID", args[0]))
+		return
+	}
+
+	if userID == update.Message.From.ID {
+		wh.reply(ctx, b, chatID, "You can not remove yourself")
+		return
+	}
+
+	if !wh.whitelist.IsUserAllowed(userID) {
+		wh.reply(ctx, b, chatID, fmt.Sprintf("User %d is not in the whitelist", userID))
+		return
+	}
+
+	if err := wh.whitelist.RemoveUser(userID); err != nil {
+		if errors.Is(err, middleware.ErrLastOwner) {
+			wh.reply(ctx, b, chatID, fmt.Sprintf("User %d is the last owner. Add another owner first", userID))
+			return
+		}
+		wh.logger.Error(err.Error())
+		wh.reply(ctx, b, chatID, "Unable to update the whitelist, try again later")
+		return
+	}
+
+	if err := wh.adminRepository.RemoveAdmin(userID); err != nil {
+		wh.logger.Error(err.Error())
+	}
+
+	wh.logger.Info(fmt.Sprintf("user %d removed user %d from the whitelist", update.Message.From.ID, userID))
+	wh.reply(ctx, b, chatID, fmt.Sprintf("User %d is removed from the whitelist", userID))
+}
+
+func (wh *WhitelistHandler) HandleInvite(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	args := strings.Fields(update.Message.Text)[1:]
+	if len(args) > 1 {
+		wh.reply(ctx, b, chatID, fmt.Sprintf("Usage: %s [viewer|operator|owner]", repo.ADMIN_COMMAND_INVITE))
+		return
+	}
+
+	role := auth.ROLE_VIEWER
+	if len(args) == 1 {
+		var err error
+		if role, err = auth.ParseRole(args[0]); err != nil {
+			wh.reply(ctx, b, chatID, err.Error())
+			return
+		}
+	}
+
+	me, err := b.GetMe(ctx)
+	if err != nil {
+		wh.logger.Error(err.Error())
+		wh.reply(ctx, b, chatID, "Unable to create invite, try again later")
+		return
+	}
+
+	invite, err := wh.invites.Create(role, update.Message.From.ID)
+	if err != nil {
+		wh.logger.Error(err.Error())
+		wh.reply(ctx, b, chatID, "Unable to create invite, try again later")
+		return
+	}
+
+	wh.logger.Info(fmt.Sprintf("user %d created an invite for role %s", update.Message.From.ID, role))
+	inviteFormat := "Single-use invite for role %s, valid until %s:\n\nhttps://t.me/%s?start=%s"
+	wh.reply(ctx, b, chatID, fmt.Sprintf(inviteFormat, role, invite.ExpiresAt.Format(time.DateTime), me.Username, invite.Token))
+}
+
+func (wh *WhitelistHandler) HandleIntruders(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+
+	intruders := wh.intruders.List()
+	if len(intruders) == 0 {
+		wh.reply(ctx, b, chatID, "No unauthorized access attempts so far")
+		return
+	}
+
+	intrudersMessage := fmt.Sprintf("Total of %d intruders:\n", len(intruders))
+	for _, intruder := range intruders {
+		intrudersMessage += fmt.Sprintf("- @%s (ID %d): %d attempts, last at %s", intruder.Username, intruder.UserID, intruder.Attempts, intruder.LastSeen.Format(time.DateTime))
+		if time.Now().Before(intruder.SilencedUntil) {
+			intrudersMessage += fmt.Sprintf(", silenced until %s", intruder.SilencedUntil.Format(time.DateTime))
+		}
+		intrudersMessage += fmt.Sprintf("\n  last message: %s\n", intruder.LastMessage)
+	}
+
+	wh.reply(ctx, b, chatID, intrudersMessage)
+}
+
+func (wh *WhitelistHandler) reply(ctx context.Context, b *bot.Bot, chatID int64, text string) {
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		Text:   text,
+		ChatID: chatID,
+	}); err != nil {
+		wh.logger.Error(err.Error())
+	}
+}
@@ -0,0 +1,91 @@
+package keyboard
+
+import (
+	"fmt"
+
+	"github.com/go-telegram/bot/models"
+
+	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+)
+
+// pageWindow is how many page number buttons are shown around the current page.
+const pageWindow = 5
+
+// Pagination splits a long list into pages. Navigation buttons
+// trigger Event with the number of the page to open as input.
+type Pagination struct {
+	PerPage int
+	Columns int
+	Event   fsm.Event
+}
+
+func (p Pagination) Pages(total int) int {
+	if total == 0 {
+		return 1
+	}
+	return (total + p.PerPage - 1) / p.PerPage
+}
+
+// Clamp returns the nearest existing page.
+func (p Pagination) Clamp(page, total int) int {
+	return min(max(page, 0), p.Pages(total)-1)
+}
+
+// Bounds returns the range of items shown on the page.
+func (p Pagination) Bounds(page, total int) (int, int) {
+	page = p.Clamp(page, total)
+	start := page * p.PerPage
+	return start, min(start+p.PerPage, total)
+}
+
+// Keyboard lays out the buttons of the page in rows of Columns buttons
+// followed by the navigation row.
+func (p Pagination) Keyboard(buttons []models.InlineKeyboardButton, page int) [][]models.InlineKeyboardButton {
+	start, end := p.Bounds(page, len(buttons))
+	columns := max(p.Columns, 1)
+
+	rows := make([][]models.InlineKeyboardButton, 0, (end-start)/columns+2)
+	for i := start; i < end; i += columns {
+		rows = append(rows, buttons[i:min(i+columns, end)])
+	}
+	if navigation := p.Navigation(page, len(buttons)); len(navigation) > 0 {
+		rows = append(rows, navigation)
+	}
+	return rows
+}
+
+// Navigation returns the previous/next buttons and the numbers of pages
+// around the current one. The row is empty when everything fits one page.
+func (p Pagination) Navigation(page, total int) []models.InlineKeyboardButton {
+	pages := p.Pages(total)
+	if pages <= 1 {
+		return nil
+	}
+	page = p.Clamp(page, total)
+
+	first := max(0, min(page-pageWindow/2, pages-pageWindow))
+	last := min(pages, first+pageWindow)
+
+	row := make([]models.InlineKeyboardButton, 0, pageWindow+2)
+	if page > 0 {
+		row = append(row, p.button("«", page-1))
+	}
+	for i := first; i < last; i++ {
+		text := fmt.Sprint(i + 1)
+		if i == page {
+			text = fmt.Sprintf("· %d ·", i+1)
+		}
+		row = append(row, p.button(text, i))
+	}
+	if page < pages-1 {
+		row = append(row, p.button("»", page+1))
+	}
+	return row
+}
+
+func (p Pagination) button(text string, page int) models.InlineKeyboardButton {
+	return models.InlineKeyboardButton{
+		Text:         text,
+		CallbackData: fmt.Sprintf("%s:%d", p.Event, page),
+	}
+}
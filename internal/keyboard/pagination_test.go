@@ -0,0 +1,127 @@
+package keyboard
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/go-telegram/bot/models"
+)
+
+var testPagination = Pagination{
+	PerPage: 10,
+	Columns: 2,
+	Event:   "pg",
+}
+
+func TestPages(t *testing.T) {
+	tests := []struct {
+		total int
+		pages int
+	}{
+		{0, 1},
+		{1, 1},
+		{10, 1},
+		{11, 2},
+		{95, 10},
+	}
+	for _, tt := range tests {
+		if got := testPagination.Pages(tt.total); got != tt.pages {
+			t.Errorf("Pages(%d) = %d, want %d", tt.total, got, tt.pages)
+		}
+	}
+}
+
+func TestBounds(t *testing.T) {
+	tests := []struct {
+		page, total int
+		start, end  int
+	}{
+		{page: 0, total: 0, start: 0, end: 0},
+		{page: 0, total: 25, start: 0, end: 10},
+		{page: 2, total: 25, start: 20, end: 25},
+		{page: 7, total: 25, start: 20, end: 25},
+		{page: -1, total: 25, start: 0, end: 10},
+	}
+	for _, tt := range tests {
+		start, end := testPagination.Bounds(tt.page, tt.total)
+		if start != tt.start || end != tt.end {
+			t.Errorf("Bounds(%d, %d) = %d, %d, want %d, %d", tt.page, tt.total, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	tests := []struct {
+		name        string
+		page, total int
+		texts       []string
+	}{
+		{name: "single page", page: 0, total: 10, texts: []string{}},
+		{name: "first of two", page: 0, total: 11, texts: []string{"· 1 ·", "2", "»"}},
+		{name: "last of two", page: 1, total: 11, texts: []string{"«", "1", "· 2 ·"}},
+		{name: "window in the middle", page: 5, total: 100, texts: []string{"«", "4", "5", "· 6 ·", "7", "8", "»"}},
+		{name: "window at the end", page: 9, total: 100, texts: []string{"«", "6", "7", "8", "9", "· 10 ·"}},
+		{name: "clamped page", page: 42, total: 25, texts: []string{"«", "1", "2", "· 3 ·"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			texts := make([]string, 0)
+			for _, button := range testPagination.Navigation(tt.page, tt.total) {
+				texts = append(texts, button.Text)
+			}
+			if !slices.Equal(texts, tt.texts) {
+				t.Errorf("Navigation(%d, %d) = %q, want %q", tt.page, tt.total, texts, tt.texts)
+			}
+		})
+	}
+}
+
+func TestNavigationCallbackData(t *testing.T) {
+	row := testPagination.Navigation(1, 30)
+	want := []string{"pg:0", "pg:0", "pg:1", "pg:2", "pg:2"}
+
+	got := make([]string, 0, len(row))
+	for _, button := range row {
+		got = append(got, button.CallbackData)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("callback data = %q, want %q", got, want)
+	}
+}
+
+func TestKeyboard(t *testing.T) {
+	buttons := make([]models.InlineKeyboardButton, 0, 25)
+	for i := range 25 {
+		buttons = append(buttons, models.InlineKeyboardButton{Text: fmt.Sprint(i)})
+	}
+
+	tests := []struct {
+		name       string
+		pagination Pagination
+		buttons    int
+		page       int
+		rowSizes   []int
+		first      string
+	}{
+		{name: "first page", pagination: testPagination, buttons: 25, page: 0, rowSizes: []int{2, 2, 2, 2, 2, 4}, first: "0"},
+		{name: "last page", pagination: testPagination, buttons: 25, page: 2, rowSizes: []int{2, 2, 1, 4}, first: "20"},
+		{name: "fits one page", pagination: testPagination, buttons: 3, page: 0, rowSizes: []int{2, 1}, first: "0"},
+		{name: "zero columns", pagination: Pagination{PerPage: 3, Event: "pg"}, buttons: 3, page: 0, rowSizes: []int{1, 1, 1}, first: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := tt.pagination.Keyboard(buttons[:tt.buttons], tt.page)
+			sizes := make([]int, 0, len(rows))
+			for _, row := range rows {
+				sizes = append(sizes, len(row))
+			}
+			if !slices.Equal(sizes, tt.rowSizes) {
+				t.Errorf("row sizes = %v, want %v", sizes, tt.rowSizes)
+			}
+			if rows[0][0].Text != tt.first {
+				t.Errorf("first button = %s, want %s", rows[0][0].Text, tt.first)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// sentMessages collects the texts the bot sends through the fake API.
+type sentMessages struct {
+	texts []string
+	mu    sync.Mutex
+}
+
+func (sm *sentMessages) all() []string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return append([]string(nil), sm.texts...)
+}
+
+// newTestBot returns a bot talking to a fake Telegram API
+// that accepts every sendMessage call.
+func newTestBot(t *testing.T) (*bot.Bot, *sentMessages) {
+	t.Helper()
+
+	sent := &sentMessages{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path.Base(r.URL.Path) == "sendMessage" {
+			if err := r.ParseMultipartForm(1 << 20); err == nil {
+				sent.mu.Lock()
+				sent.texts = append(sent.texts, r.FormValue("text"))
+				sent.mu.Unlock()
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":1,"type":"private"}}}`)
+	}))
+	t.Cleanup(server.Close)
+
+	b, err := bot.New("1:test", bot.WithServerURL(server.URL), bot.WithSkipGetMe())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, sent
+}
+
+func messageFrom(userID int64, text string) *models.Update {
+	return &models.Update{Message: &models.Message{
+		From: &models.User{ID: userID},
+		Chat: models.Chat{ID: userID},
+		Text: text,
+	}}
+}
+
+func callbackFrom(userID int64, data string) *models.Update {
+	return &models.Update{CallbackQuery: &models.CallbackQuery{
+		From: models.User{ID: userID},
+		Data: data,
+	}}
+}
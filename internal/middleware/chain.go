@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"github.com/go-telegram/bot"
+)
+
+// Middleware wraps a handler. It may short-circuit the update
+// by returning without calling the next handler.
+type Middleware = bot.Middleware
+
+// Chain applies middlewares in the order they were added,
+// so the first one sees the update first.
+type Chain struct {
+	middlewares []Middleware
+}
+
+func NewChain(middlewares ...Middleware) Chain {
+	return Chain{
+		middlewares: middlewares,
+	}
+}
+
+// Append returns a new chain with the middlewares added
+// after the ones already in the chain.
+func (c Chain) Append(middlewares ...Middleware) Chain {
+	joined := make([]Middleware, 0, len(c.middlewares)+len(middlewares))
+	joined = append(joined, c.middlewares...)
+	joined = append(joined, middlewares...)
+	return Chain{
+		middlewares: joined,
+	}
+}
+
+func (c Chain) Then(handler bot.HandlerFunc) bot.HandlerFunc {
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		handler = c.middlewares[i](handler)
+	}
+	return handler
+}
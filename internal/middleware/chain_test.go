@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+func tracing(name string, trace *[]string, stop bool) Middleware {
+	return func(next bot.HandlerFunc) bot.HandlerFunc {
+		return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+			*trace = append(*trace, name)
+			if stop {
+				return
+			}
+			next(ctx, b, update)
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	tests := []struct {
+		name  string
+		stops []bool
+		trace []string
+	}{
+		{name: "empty chain", stops: nil, trace: []string{"handler"}},
+		{name: "in order", stops: []bool{false, false, false}, trace: []string{"0", "1", "2", "handler"}},
+		{name: "first short-circuits", stops: []bool{true, false}, trace: []string{"0"}},
+		{name: "middle short-circuits", stops: []bool{false, true, false}, trace: []string{"0", "1"}},
+		{name: "last short-circuits", stops: []bool{false, false, true}, trace: []string{"0", "1", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace := make([]string, 0)
+			chain := NewChain()
+			for i, stop := range tt.stops {
+				// Append must not change the chain it is called on.
+				chain.Append(tracing("ignored", &trace, true))
+				chain = chain.Append(tracing(string(rune('0'+i)), &trace, stop))
+			}
+
+			chain.Then(func(context.Context, *bot.Bot, *models.Update) {
+				trace = append(trace, "handler")
+			})(context.Background(), nil, &models.Update{})
+
+			if !slices.Equal(trace, tt.trace) {
+				t.Errorf("trace = %v, want %v", trace, tt.trace)
+			}
+		})
+	}
+}
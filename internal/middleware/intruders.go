@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+const intruderPreviewLength = 64
+
+type Intruder struct {
+	UserID        int64
+	Username      string
+	Attempts      int
+	Lockouts      int
+	FirstSeen     time.Time
+	LastSeen      time.Time
+	LastMessage   string
+	SilencedUntil time.Time
+}
+
+// IntruderTracker counts updates from users outside the whitelist.
+// Every threshold attempts the user is silenced: the bot stops replying
+// to them for a period that doubles with every lockout up to maxSilence.
+type IntruderTracker struct {
+	threshold  int
+	silence    time.Duration
+	maxSilence time.Duration
+	intruders  map[int64]*Intruder
+	mu         sync.Mutex
+}
+
+func NewIntruderTracker(threshold int, silence, maxSilence time.Duration) *IntruderTracker {
+	return &IntruderTracker{
+		threshold:  max(threshold, 1),
+		silence:    silence,
+		maxSilence: maxSilence,
+		intruders:  make(map[int64]*Intruder),
+	}
+}
+
+// Record registers an attempt. It reports whether the user is silenced
+// and whether the attempt started a new lockout owners should know about.
+func (it *IntruderTracker) Record(userID int64, username, message string) (Intruder, bool, bool) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	now := time.Now()
+	intruder, ok := it.intruders[userID]
+	if !ok {
+		intruder = &Intruder{UserID: userID, FirstSeen: now}
+		it.intruders[userID] = intruder
+	}
+
+	intruder.Username = username
+	intruder.Attempts++
+	intruder.LastSeen = now
+	if preview := []rune(message); len(preview) > intruderPreviewLength {
+		intruder.LastMessage = string(preview[:intruderPreviewLength]) + "…"
+	} else {
+		intruder.LastMessage = message
+	}
+
+	if now.Before(intruder.SilencedUntil) {
+		return *intruder, true, false
+	}
+
+	if intruder.Attempts%it.threshold != 0 {
+		return *intruder, false, false
+	}
+
+	silence := it.silence
+	for i := 0; i < intruder.Lockouts && silence < it.maxSilence; i++ {
+		silence *= 2
+	}
+	silence = min(silence, it.maxSilence)
+	intruder.Lockouts++
+	intruder.SilencedUntil = now.Add(silence)
+	return *intruder, false, true
+}
+
+// List returns all known intruders, the most recently seen first.
+func (it *IntruderTracker) List() []Intruder {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	intruders := make([]Intruder, 0, len(it.intruders))
+	for _, intruder := range it.intruders {
+		intruders = append(intruders, *intruder)
+	}
+	slices.SortFunc(intruders, func(a, b Intruder) int {
+		return b.LastSeen.Compare(a.LastSeen)
+	})
+	return intruders
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIntruderSilence(t *testing.T) {
+	type attempt struct {
+		// expire ends the current silence before the attempt.
+		expire   bool
+		silenced bool
+		lockout  bool
+		// silence is how long the user is silenced after a lockout.
+		silence time.Duration
+	}
+	tests := []struct {
+		name       string
+		threshold  int
+		silence    time.Duration
+		maxSilence time.Duration
+		attempts   []attempt
+	}{
+		{
+			name:      "locked out every threshold attempts",
+			threshold: 2, silence: time.Minute, maxSilence: time.Hour,
+			attempts: []attempt{
+				{},
+				{lockout: true, silence: time.Minute},
+				{silenced: true},
+				{silenced: true},
+			},
+		},
+		{
+			name:      "silence doubles up to the maximum",
+			threshold: 1, silence: time.Minute, maxSilence: 5 * time.Minute,
+			attempts: []attempt{
+				{lockout: true, silence: time.Minute},
+				{expire: true, lockout: true, silence: 2 * time.Minute},
+				{expire: true, lockout: true, silence: 4 * time.Minute},
+				{expire: true, lockout: true, silence: 5 * time.Minute},
+				{expire: true, lockout: true, silence: 5 * time.Minute},
+			},
+		},
+		{
+			name:      "attempts while silenced still count",
+			threshold: 2, silence: time.Minute, maxSilence: time.Hour,
+			attempts: []attempt{
+				{},
+				{lockout: true, silence: time.Minute},
+				{silenced: true},
+				{expire: true, lockout: true, silence: 2 * time.Minute},
+				{silenced: true},
+			},
+		},
+		{
+			name:      "zero threshold locks out on every attempt",
+			threshold: 0, silence: time.Minute, maxSilence: time.Hour,
+			attempts: []attempt{
+				{lockout: true, silence: time.Minute},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewIntruderTracker(tt.threshold, tt.silence, tt.maxSilence)
+			for i, a := range tt.attempts {
+				if a.expire {
+					tracker.intruders[1].SilencedUntil = time.Now().Add(-time.Second)
+				}
+				intruder, silenced, lockout := tracker.Record(1, "mallory", "hi")
+				if silenced != a.silenced || lockout != a.lockout {
+					t.Fatalf("attempt %d: Record = %t, %t, want %t, %t", i, silenced, lockout, a.silenced, a.lockout)
+				}
+				if intruder.Attempts != i+1 {
+					t.Errorf("attempt %d: attempts = %d, want %d", i, intruder.Attempts, i+1)
+				}
+				if a.lockout {
+					if silence := intruder.SilencedUntil.Sub(intruder.LastSeen); silence != a.silence {
+						t.Errorf("attempt %d: silence = %s, want %s", i, silence, a.silence)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/luckyComet55/marzban-tg-bot/internal/auth"
+)
+
+func Invite(whitelist *WhitelistMiddleware, invites *auth.InviteStore) Middleware {
+	return func(next bot.HandlerFunc) bot.HandlerFunc {
+		return WithInvite(whitelist, invites, next)
+	}
+}
+
+// WithInvite redeems the invite token passed as "/start <token>" and adds
+// its owner to the whitelist before handing the update to the handler.
+// Whitelisted users and messages without a token pass through untouched.
+func WithInvite(whitelist *WhitelistMiddleware, invites *auth.InviteStore, handler bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		if update.Message == nil || update.Message.From == nil {
+			handler(ctx, b, update)
+			return
+		}
+
+		userID := update.Message.From.ID
+		_, token, _ := strings.Cut(update.Message.Text, " ")
+		token = strings.TrimSpace(token)
+		if token == "" || whitelist.IsUserAllowed(userID) {
+			handler(ctx, b, update)
+			return
+		}
+
+		invite, err := invites.Redeem(token)
+		if err != nil {
+			if !errors.Is(err, auth.ErrInviteInvalid) {
+				whitelist.logger.Error(err.Error())
+			}
+			whitelist.logger.Warn(fmt.Sprintf("user %s (ID %d) used an invalid invite", update.Message.From.Username, userID))
+			handler(ctx, b, update)
+			return
+		}
+
+		if err := whitelist.SetUserRole(userID, invite.Role); err != nil {
+			whitelist.logger.Error(err.Error())
+			if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+				Text:   "Unable to accept your invite, try again later",
+				ChatID: update.Message.Chat.ID,
+			}); err != nil {
+				whitelist.logger.Error(err.Error())
+			}
+			return
+		}
+
+		whitelist.logger.Info(fmt.Sprintf("user %s (ID %d) joined as %s by invite of %d", update.Message.From.Username, userID, invite.Role, invite.CreatedBy))
+		if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			Text:   fmt.Sprintf("Invite accepted, you joined as %s", invite.Role),
+			ChatID: update.Message.Chat.ID,
+		}); err != nil {
+			whitelist.logger.Error(err.Error())
+		}
+
+		handler(ctx, b, update)
+	}
+}
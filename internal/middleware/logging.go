@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+type requestIDKey struct{}
+
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// RequestID tags every update with a random ID, so that log
+// records of a single update can be found together.
+func RequestID(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		raw := make([]byte, 8)
+		rand.Read(raw)
+		next(context.WithValue(ctx, requestIDKey{}, hex.EncodeToString(raw)), b, update)
+	}
+}
+
+func Logging(logger *slog.Logger) Middleware {
+	return func(next bot.HandlerFunc) bot.HandlerFunc {
+		return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+			sender, _ := senderOf(update)
+			updateLogger := logger.With(
+				"request_id", RequestIDFromContext(ctx),
+				"update_id", update.ID,
+				"user_id", sender.userID,
+				"event", eventOf(update),
+			)
+
+			updateLogger.Debug("handling update")
+			start := time.Now()
+			next(ctx, b, update)
+			updateLogger.Debug("handled update", "duration", time.Since(start))
+		}
+	}
+}
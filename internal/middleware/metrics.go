@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsMiddleware counts handled updates and measures how long
+// handlers take. Updates are labelled by kind and action class
+// only, so that free text input does not blow up label cardinality.
+type MetricsMiddleware struct {
+	updates  *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func NewMetricsMiddleware(registerer prometheus.Registerer) *MetricsMiddleware {
+	mm := &MetricsMiddleware{
+		updates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "marzban_bot",
+			Name:      "updates_total",
+			Help:      "Updates passed to handlers by kind and action class.",
+		}, []string{"kind", "class"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "marzban_bot",
+			Name:      "update_duration_seconds",
+			Help:      "Time spent handling an update by action class.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"class"}),
+	}
+	registerer.MustRegister(mm.updates, mm.duration)
+	return mm
+}
+
+func (mm *MetricsMiddleware) Wrap(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		class := string(classOf(eventOf(update)))
+		mm.updates.WithLabelValues(kindOf(update), class).Inc()
+
+		start := time.Now()
+		next(ctx, b, update)
+		mm.duration.WithLabelValues(class).Observe(time.Since(start).Seconds())
+	}
+}
+
+func kindOf(update *models.Update) string {
+	switch {
+	case update.Message != nil:
+		return "message"
+	case update.CallbackQuery != nil:
+		return "callback_query"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+)
+
+type ActionClass string
+
+const (
+	ACTION_CLASS_READ   ActionClass = "read"
+	ACTION_CLASS_MUTATE ActionClass = "mutate"
+)
+
+// mutatingEvents change data on the Marzban panel or in the bot itself.
+// Every other event is limited as a read.
+var mutatingEvents = map[fsm.Event]bool{
+	repo.ADMIN_EVENT_SUBMIT_CREATE: true,
+	repo.ADMIN_EVENT_CONFIRM_BULK:  true,
+	repo.ADMIN_EVENT_BULK_CREATE:   true,
+
+	repo.ADMIN_COMMAND_ADMIN_ADD:    true,
+	repo.ADMIN_COMMAND_ADMIN_REMOVE: true,
+	repo.ADMIN_COMMAND_INVITE:       true,
+	repo.ADMIN_COMMAND_TEMPLATES:    true,
+	repo.ADMIN_COMMAND_META_RENAME:  true,
+	repo.ADMIN_COMMAND_TOTP_ENROLL:  true,
+	repo.ADMIN_COMMAND_TOTP_CONFIRM: true,
+	repo.ADMIN_COMMAND_TOTP_DISABLE: true,
+}
+
+func classOf(event fsm.Event) ActionClass {
+	if mutatingEvents[event] {
+		return ACTION_CLASS_MUTATE
+	}
+	return ACTION_CLASS_READ
+}
+
+type RateLimit struct {
+	PerMinute int
+	Burst     int
+}
+
+type bucketKey struct {
+	userID int64
+	class  ActionClass
+}
+
+// tokenBucket holds up to burst tokens and regains them at a constant rate.
+// notified is set once the user was told to slow down, so that a flood of
+// updates gets a single reply instead of one per update.
+type tokenBucket struct {
+	tokens   float64
+	updated  time.Time
+	notified bool
+}
+
+type RateLimitMiddleware struct {
+	logger  *slog.Logger
+	limits  map[ActionClass]RateLimit
+	buckets map[bucketKey]*tokenBucket
+	mu      sync.Mutex
+}
+
+func NewRateLimitMiddleware(limits map[ActionClass]RateLimit, logger *slog.Logger) *RateLimitMiddleware {
+	return &RateLimitMiddleware{
+		logger:  logger,
+		limits:  limits,
+		buckets: make(map[bucketKey]*tokenBucket),
+	}
+}
+
+// Allow takes a token from the user's bucket for the action class.
+// When the bucket is empty it returns the time until the next token
+// and whether the user has to be notified about it.
+func (rl *RateLimitMiddleware) Allow(userID int64, class ActionClass) (bool, time.Duration, bool) {
+	limit, ok := rl.limits[class]
+	if !ok || limit.PerMinute <= 0 {
+		return true, 0, false
+	}
+	rate := float64(limit.PerMinute) / time.Minute.Seconds()
+	burst := float64(max(limit.Burst, 1))
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	key := bucketKey{userID, class}
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, updated: now}
+		rl.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*rate)
+	bucket.updated = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		bucket.notified = false
+		return true, 0, false
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	notify := !bucket.notified
+	bucket.notified = true
+	return false, wait, notify
+}
+
+func (rl *RateLimitMiddleware) Wrap(next bot.HandlerFunc) bot.HandlerFunc {
+	return WithRateLimit(rl, next)
+}
+
+func WithRateLimit(limiter *RateLimitMiddleware, handler bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		sender, ok := senderOf(update)
+		if !ok {
+			handler(ctx, b, update)
+			return
+		}
+
+		event := eventOf(update)
+		allowed, wait, notify := limiter.Allow(sender.userID, classOf(event))
+		if allowed {
+			handler(ctx, b, update)
+			return
+		}
+
+		limiter.logger.Warn(fmt.Sprintf("user %s (ID %d) is rate limited on '%s'", sender.username, sender.userID, event))
+		if !notify {
+			return
+		}
+
+		if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			Text:   fmt.Sprintf("Please slow down a little, try again in %d seconds", int(math.Ceil(wait.Seconds()))),
+			ChatID: sender.chatID,
+		}); err != nil {
+			limiter.logger.Error(err.Error())
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+)
+
+func TestAllow(t *testing.T) {
+	type step struct {
+		// elapsed moves the bucket's last update back in time
+		// before the call, as if that much time passed.
+		elapsed time.Duration
+		allowed bool
+		notify  bool
+	}
+	tests := []struct {
+		name  string
+		limit RateLimit
+		steps []step
+	}{
+		{
+			name:  "burst then empty",
+			limit: RateLimit{PerMinute: 60, Burst: 3},
+			steps: []step{{allowed: true}, {allowed: true}, {allowed: true}, {notify: true}, {}},
+		},
+		{
+			name:  "refills at the rate",
+			limit: RateLimit{PerMinute: 60, Burst: 1},
+			steps: []step{{allowed: true}, {notify: true}, {elapsed: time.Second, allowed: true}, {notify: true}},
+		},
+		{
+			name:  "refill never exceeds the burst",
+			limit: RateLimit{PerMinute: 60, Burst: 2},
+			steps: []step{{allowed: true}, {elapsed: time.Hour, allowed: true}, {allowed: true}, {notify: true}},
+		},
+		{
+			name:  "zero burst holds one token",
+			limit: RateLimit{PerMinute: 60},
+			steps: []step{{allowed: true}, {notify: true}},
+		},
+		{
+			name:  "unlimited",
+			limit: RateLimit{},
+			steps: []step{{allowed: true}, {allowed: true}, {allowed: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimitMiddleware(map[ActionClass]RateLimit{ACTION_CLASS_MUTATE: tt.limit}, discardLogger)
+			for i, s := range tt.steps {
+				if bucket, ok := limiter.buckets[bucketKey{1, ACTION_CLASS_MUTATE}]; ok {
+					bucket.updated = bucket.updated.Add(-s.elapsed)
+				}
+				allowed, wait, notify := limiter.Allow(1, ACTION_CLASS_MUTATE)
+				if allowed != s.allowed || notify != s.notify {
+					t.Errorf("step %d: Allow = %t, %t, want %t, %t", i, allowed, notify, s.allowed, s.notify)
+				}
+				if !allowed && (wait <= 0 || wait > time.Second) {
+					t.Errorf("step %d: wait = %s, want up to a second", i, wait)
+				}
+			}
+
+			if allowed, _, _ := limiter.Allow(2, ACTION_CLASS_MUTATE); !allowed {
+				t.Error("buckets are shared between users")
+			}
+			if allowed, _, _ := limiter.Allow(1, ACTION_CLASS_READ); !allowed {
+				t.Error("buckets are shared between action classes")
+			}
+		})
+	}
+}
+
+func TestClassOf(t *testing.T) {
+	if class := classOf(repo.ADMIN_EVENT_CONFIRM_BULK); class != ACTION_CLASS_MUTATE {
+		t.Errorf("classOf(%s) = %s, want %s", repo.ADMIN_EVENT_CONFIRM_BULK, class, ACTION_CLASS_MUTATE)
+	}
+	if class := classOf(repo.ADMIN_EVENT_LIST_USERS); class != ACTION_CLASS_READ {
+		t.Errorf("classOf(%s) = %s, want %s", repo.ADMIN_EVENT_LIST_USERS, class, ACTION_CLASS_READ)
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const incidentAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func newIncidentID() string {
+	raw := make([]byte, 6)
+	rand.Read(raw)
+	for i, c := range raw {
+		raw[i] = incidentAlphabet[int(c)%len(incidentAlphabet)]
+	}
+	return string(raw)
+}
+
+// Recovery stops a panic in a single update from taking the bot down.
+// The stack is logged under a short incident ID, which is also shown
+// to the admin, so that a report can be matched with the logs.
+func Recovery(logger *slog.Logger) Middleware {
+	return func(next bot.HandlerFunc) bot.HandlerFunc {
+		return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+			defer func() {
+				reason := recover()
+				if reason == nil {
+					return
+				}
+
+				incidentID := newIncidentID()
+				logger.Error(fmt.Sprintf("recovered from panic: %v", reason),
+					"incident_id", incidentID,
+					"request_id", RequestIDFromContext(ctx),
+					"update_id", update.ID,
+					"stack", string(debug.Stack()),
+				)
+
+				sender, ok := senderOf(update)
+				if !ok {
+					return
+				}
+				if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+					Text:   fmt.Sprintf("Internal error (ref %s)", incidentID),
+					ChatID: sender.chatID,
+				}); err != nil {
+					logger.Error(err.Error(), "incident_id", incidentID)
+				}
+			}()
+
+			next(ctx, b, update)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+var incidentReplyRegexp = regexp.MustCompile(`^Internal error \(ref [A-HJ-NP-Z2-9]{6}\)$`)
+
+func TestRecovery(t *testing.T) {
+	tests := []struct {
+		name    string
+		update  *models.Update
+		panics  bool
+		replies int
+	}{
+		{name: "no panic", update: messageFrom(1, "hi")},
+		{name: "panic in a message", update: messageFrom(1, "hi"), panics: true, replies: 1},
+		{name: "panic in a callback", update: callbackFrom(1, "ud:alice"), panics: true, replies: 1},
+		{name: "panic without a sender", update: &models.Update{}, panics: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, sent := newTestBot(t)
+			handled := false
+			handler := Recovery(discardLogger)(func(context.Context, *bot.Bot, *models.Update) {
+				handled = true
+				if tt.panics {
+					panic("boom")
+				}
+			})
+
+			handler(context.Background(), b, tt.update)
+
+			if !handled {
+				t.Error("handler was not called")
+			}
+			texts := sent.all()
+			if len(texts) != tt.replies {
+				t.Fatalf("replies = %q, want %d", texts, tt.replies)
+			}
+			for _, text := range texts {
+				if !incidentReplyRegexp.MatchString(text) {
+					t.Errorf("reply = %q, want an incident reference", text)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/luckyComet55/marzban-tg-bot/internal/auth"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/totp"
+)
+
+var totpCodeRegexp = regexp.MustCompile(fmt.Sprintf("^[0-9]{%d}$", totp.Digits))
+
+type pendingUpdate struct {
+	update    *models.Update
+	handler   bot.HandlerFunc
+	expiresAt time.Time
+}
+
+// TOTPMiddleware holds back sensitive events of admins who enrolled TOTP
+// until they reply with a valid code. The held update is then handed
+// to the handler it was meant for as if it just arrived.
+type TOTPMiddleware struct {
+	logger  *slog.Logger
+	secrets *auth.TOTPStore
+	events  map[fsm.Event]bool
+	timeout time.Duration
+	pending map[int64]pendingUpdate
+	mu      sync.Mutex
+}
+
+func NewTOTPMiddleware(secrets *auth.TOTPStore, events []fsm.Event, timeout time.Duration, logger *slog.Logger) *TOTPMiddleware {
+	sensitive := make(map[fsm.Event]bool, len(events))
+	for _, event := range events {
+		sensitive[event] = true
+	}
+
+	return &TOTPMiddleware{
+		logger:  logger,
+		secrets: secrets,
+		events:  sensitive,
+		timeout: timeout,
+		pending: make(map[int64]pendingUpdate),
+	}
+}
+
+func (tm *TOTPMiddleware) hold(userID int64, update *models.Update, handler bot.HandlerFunc) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.pending[userID] = pendingUpdate{
+		update:    update,
+		handler:   handler,
+		expiresAt: time.Now().Add(tm.timeout),
+	}
+}
+
+// release returns the update held for the user, if it has not expired yet,
+// and forgets it. It is called only for code replies, so that other
+// updates do not cancel a pending confirmation.
+func (tm *TOTPMiddleware) release(userID int64) (pendingUpdate, bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	pending, ok := tm.pending[userID]
+	if !ok {
+		return pendingUpdate{}, false
+	}
+	delete(tm.pending, userID)
+
+	if time.Now().After(pending.expiresAt) {
+		return pendingUpdate{}, false
+	}
+	return pending, true
+}
+
+// isCodeReply reports whether the update is a plain message
+// that looks like a TOTP code.
+func isCodeReply(update *models.Update) bool {
+	return update.Message != nil && totpCodeRegexp.MatchString(strings.TrimSpace(update.Message.Text))
+}
+
+func (tm *TOTPMiddleware) Wrap(next bot.HandlerFunc) bot.HandlerFunc {
+	return WithTOTP(tm, next)
+}
+
+func WithTOTP(confirmation *TOTPMiddleware, handler bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		sender, ok := senderOf(update)
+		if !ok {
+			handler(ctx, b, update)
+			return
+		}
+
+		if isCodeReply(update) {
+			held, ok := confirmation.release(sender.userID)
+			if !ok {
+				handler(ctx, b, update)
+				return
+			}
+			if err := confirmation.secrets.Verify(sender.userID, strings.TrimSpace(update.Message.Text)); err != nil {
+				if !errors.Is(err, auth.ErrTOTPInvalidCode) {
+					confirmation.logger.Error(err.Error())
+				}
+				confirmation.logger.Warn(fmt.Sprintf("user %s (ID %d) failed TOTP confirmation", sender.username, sender.userID))
+				confirmation.reply(ctx, b, sender.chatID, "Wrong code, the action is cancelled")
+				return
+			}
+
+			held.handler(ctx, b, held.update)
+			return
+		}
+
+		if event := eventOf(update); confirmation.events[event] && confirmation.secrets.IsEnrolled(sender.userID) {
+			confirmation.hold(sender.userID, update, handler)
+			confirmation.reply(ctx, b, sender.chatID, fmt.Sprintf("Enter the code from your authenticator app within %s to confirm", confirmation.timeout))
+			return
+		}
+
+		handler(ctx, b, update)
+	}
+}
+
+func (tm *TOTPMiddleware) reply(ctx context.Context, b *bot.Bot, chatID int64, text string) {
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		Text:   text,
+		ChatID: chatID,
+	}); err != nil {
+		tm.logger.Error(err.Error())
+	}
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/luckyComet55/marzban-tg-bot/internal/auth"
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/storage"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/totp"
+)
+
+const (
+	enrolledAdmin   = 1
+	unenrolledAdmin = 2
+)
+
+// newEnrolledStore returns a store where enrolledAdmin confirmed TOTP
+// with the code of the current period, and that admin's secret.
+func newEnrolledStore(t *testing.T) (*auth.TOTPStore, string) {
+	t.Helper()
+
+	store, err := auth.NewTOTPStore(storage.NewJSONFile[map[int64]auth.TOTPSecret](filepath.Join(t.TempDir(), "totp.json")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := store.Enroll(enrolledAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.Code(secret, totp.Step(time.Now())-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Verify(enrolledAdmin, code); err != nil {
+		t.Fatal(err)
+	}
+	return store, secret
+}
+
+func TestTOTP(t *testing.T) {
+	// code stands for a message with the code of the period
+	// offset periods away from the current one.
+	type code struct {
+		offset int64
+	}
+	type step struct {
+		update  *models.Update
+		code    *code
+		handled string
+	}
+	sensitive := callbackFrom(enrolledAdmin, string(repo.ADMIN_EVENT_CONFIRM_BULK))
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		steps   []step
+	}{
+		{
+			name:    "read events pass",
+			timeout: time.Minute,
+			steps:   []step{{update: callbackFrom(enrolledAdmin, "ud:alice"), handled: "ud:alice"}},
+		},
+		{
+			name:    "admins without TOTP pass",
+			timeout: time.Minute,
+			steps:   []step{{update: callbackFrom(unenrolledAdmin, string(repo.ADMIN_EVENT_CONFIRM_BULK)), handled: "bcf"}},
+		},
+		{
+			name:    "held until the code",
+			timeout: time.Minute,
+			steps: []step{
+				{update: sensitive},
+				{update: callbackFrom(enrolledAdmin, "ud:alice"), handled: "ud:alice"},
+				{code: &code{0}, handled: "bcf"},
+			},
+		},
+		{
+			name:    "code replays are rejected",
+			timeout: time.Minute,
+			steps: []step{
+				{update: sensitive},
+				{code: &code{0}, handled: "bcf"},
+				{update: sensitive},
+				{code: &code{0}},
+				{code: &code{1}, handled: "code"},
+			},
+		},
+		{
+			name:    "wrong code cancels the action",
+			timeout: time.Minute,
+			steps: []step{
+				{update: sensitive},
+				{update: messageFrom(enrolledAdmin, "000000")},
+				{code: &code{1}, handled: "code"},
+			},
+		},
+		{
+			name:    "codes without a held update pass",
+			timeout: time.Minute,
+			steps:   []step{{code: &code{1}, handled: "code"}},
+		},
+		{
+			name:    "expired hold is dropped",
+			timeout: -time.Second,
+			steps: []step{
+				{update: sensitive},
+				{code: &code{0}, handled: "code"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newTestBot(t)
+			store, secret := newEnrolledStore(t)
+			confirmation := NewTOTPMiddleware(store, []fsm.Event{repo.ADMIN_EVENT_CONFIRM_BULK}, tt.timeout, discardLogger)
+
+			handled := make([]string, 0)
+			handler := confirmation.Wrap(func(_ context.Context, _ *bot.Bot, update *models.Update) {
+				if isCodeReply(update) {
+					handled = append(handled, "code")
+				} else {
+					handled = append(handled, previewOf(update))
+				}
+			})
+
+			want := make([]string, 0)
+			for i, s := range tt.steps {
+				update := s.update
+				if s.code != nil {
+					value, err := totp.Code(secret, totp.Step(time.Now())+s.code.offset)
+					if err != nil {
+						t.Fatal(err)
+					}
+					update = messageFrom(enrolledAdmin, value)
+				}
+				// The wrong code must not be the right one by chance.
+				if update.Message != nil && update.Message.Text == "000000" {
+					if _, ok := totp.Validate(secret, "000000", time.Now()); ok {
+						t.Skip("000000 happens to be a valid code")
+					}
+				}
+
+				handler(context.Background(), b, update)
+				if s.handled != "" {
+					want = append(want, s.handled)
+				}
+				if !slices.Equal(handled, want) {
+					t.Fatalf("step %d: handled = %q, want %q", i, handled, want)
+				}
+			}
+		})
+	}
+}
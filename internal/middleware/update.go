@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/go-telegram/bot/models"
+
+	repo "github.com/luckyComet55/marzban-tg-bot/internal/repository"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/fsm"
+)
+
+type updateSender struct {
+	userID   int64
+	chatID   int64
+	username string
+}
+
+// senderOf returns who sent the update. The flag is false
+// for updates that are neither messages nor callback queries
+// and for messages without a sender, like channel posts.
+func senderOf(update *models.Update) (updateSender, bool) {
+	if update.Message != nil {
+		if update.Message.From == nil {
+			return updateSender{}, false
+		}
+		return updateSender{
+			userID:   update.Message.From.ID,
+			chatID:   update.Message.Chat.ID,
+			username: update.Message.From.Username,
+		}, true
+	}
+	if update.CallbackQuery != nil {
+		return updateSender{
+			userID:   update.CallbackQuery.From.ID,
+			chatID:   update.CallbackQuery.From.ID,
+			username: update.CallbackQuery.From.Username,
+		}, true
+	}
+	return updateSender{}, false
+}
+
+// eventOf returns the event the update is going to trigger: a bot command,
+// the callback data prefix, or the default event for plain text input.
+func eventOf(update *models.Update) fsm.Event {
+	if update.Message != nil && strings.HasPrefix(update.Message.Text, "/") {
+		command, _, _ := strings.Cut(update.Message.Text, " ")
+		command, _, _ = strings.Cut(command, "@")
+		return fsm.Event(command)
+	}
+	if update.CallbackQuery != nil {
+		if name, _, _ := strings.Cut(update.CallbackQuery.Data, ":"); name != "" {
+			return fsm.Event(name)
+		}
+	}
+	return repo.ADMIN_EVENT_NEXT
+}
+
+// previewOf returns what the user sent: the message text or the callback data.
+func previewOf(update *models.Update) string {
+	if update.Message != nil {
+		return update.Message.Text
+	}
+	if update.CallbackQuery != nil {
+		return update.CallbackQuery.Data
+	}
+	return ""
+}
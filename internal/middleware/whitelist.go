@@ -2,52 +2,154 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"slices"
+	"maps"
+	"sync"
+	"time"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
+
+	"github.com/luckyComet55/marzban-tg-bot/internal/auth"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/storage"
 )
 
+// ErrLastOwner is returned when a change would leave the whitelist
+// without owners, so that nobody could manage admins anymore.
+var ErrLastOwner = errors.New("the last owner can not be demoted or removed")
+
 type WhitelistMiddleware struct {
-	logger         *slog.Logger
-	userAllowedIDs []int64
+	logger    *slog.Logger
+	store     *storage.JSONFile[map[int64]auth.Role]
+	intruders *IntruderTracker
+	userRoles map[int64]auth.Role
+	mu        sync.RWMutex
 }
 
-func NewWhitelistMiddleware(userAllowedIDs []int64, logger *slog.Logger) *WhitelistMiddleware {
-	return &WhitelistMiddleware{
-		userAllowedIDs: userAllowedIDs,
-		logger:         logger,
+// NewWhitelistMiddleware loads the whitelist from store. The seed roles
+// are used and persisted only when the store file does not exist yet.
+func NewWhitelistMiddleware(seed map[int64]auth.Role, store *storage.JSONFile[map[int64]auth.Role], intruders *IntruderTracker, logger *slog.Logger) (*WhitelistMiddleware, error) {
+	userRoles, ok, err := store.Load()
+	if err != nil {
+		return nil, err
 	}
+	if !ok || userRoles == nil {
+		logger.Info(fmt.Sprintf("no whitelist at %s, seeding it with %d users", store.Path(), len(seed)))
+		userRoles = maps.Clone(seed)
+		if err := store.Save(userRoles); err != nil {
+			return nil, err
+		}
+	}
+
+	return &WhitelistMiddleware{
+		userRoles: userRoles,
+		store:     store,
+		intruders: intruders,
+		logger:    logger,
+	}, nil
 }
 
 func (wm *WhitelistMiddleware) IsUserAllowed(userID int64) bool {
-	return slices.Contains(wm.userAllowedIDs, userID)
+	return wm.GetUserRole(userID) != auth.ROLE_NONE
+}
+
+func (wm *WhitelistMiddleware) GetUserRole(userID int64) auth.Role {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	return wm.userRoles[userID]
+}
+
+func (wm *WhitelistMiddleware) ListUsers() map[int64]auth.Role {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	return maps.Clone(wm.userRoles)
+}
+
+// SetUserRole adds the user to the whitelist or changes the role
+// of an already whitelisted one.
+func (wm *WhitelistMiddleware) SetUserRole(userID int64, role auth.Role) error {
+	if role == auth.ROLE_NONE {
+		return fmt.Errorf("role %s can not be assigned", role)
+	}
+
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	if role != auth.ROLE_OWNER && wm.isLastOwner(userID) {
+		return ErrLastOwner
+	}
+
+	updated := maps.Clone(wm.userRoles)
+	updated[userID] = role
+	if err := wm.store.Save(updated); err != nil {
+		return err
+	}
+	wm.userRoles = updated
+	return nil
+}
+
+func (wm *WhitelistMiddleware) RemoveUser(userID int64) error {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	if _, ok := wm.userRoles[userID]; !ok {
+		return fmt.Errorf("user with ID %d is not in the whitelist", userID)
+	}
+	if wm.isLastOwner(userID) {
+		return ErrLastOwner
+	}
+
+	updated := maps.Clone(wm.userRoles)
+	delete(updated, userID)
+	if err := wm.store.Save(updated); err != nil {
+		return err
+	}
+	wm.userRoles = updated
+	return nil
+}
+
+// isLastOwner must be called with wm.mu held.
+func (wm *WhitelistMiddleware) isLastOwner(userID int64) bool {
+	if wm.userRoles[userID] != auth.ROLE_OWNER {
+		return false
+	}
+	for id, role := range wm.userRoles {
+		if id != userID && role == auth.ROLE_OWNER {
+			return false
+		}
+	}
+	return true
+}
+
+func (wm *WhitelistMiddleware) Wrap(next bot.HandlerFunc) bot.HandlerFunc {
+	return WithWhitelist(wm, next)
 }
 
 func WithWhitelist(whitelist *WhitelistMiddleware, handler bot.HandlerFunc) bot.HandlerFunc {
 	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
-		var userID, chatID int64
-		var username string
-		if update.Message != nil {
-			userID = update.Message.From.ID
-			chatID = update.Message.Chat.ID
-			username = update.Message.From.Username
-		} else if update.CallbackQuery != nil {
-			userID = update.CallbackQuery.From.ID
-			chatID = update.CallbackQuery.From.ID
-			username = update.CallbackQuery.From.Username
-		} else {
-			handler(ctx, b, update)
+		// Updates without a sender can not be checked, so they are dropped.
+		sender, ok := senderOf(update)
+		if !ok {
 			return
 		}
-		if !whitelist.IsUserAllowed(userID) {
-			whitelist.logger.Warn(fmt.Sprintf("user %s (ID %d) is not in the whitelist", username, userID))
+		if !whitelist.IsUserAllowed(sender.userID) {
+			whitelist.logger.Warn(fmt.Sprintf("user %s (ID %d) is not in the whitelist", sender.username, sender.userID))
+
+			intruder, silenced, alert := whitelist.intruders.Record(sender.userID, sender.username, previewOf(update))
+			if alert {
+				whitelist.alertOwners(ctx, b, intruder)
+			}
+			if silenced {
+				return
+			}
 
 			_, err := b.SendMessage(ctx, &bot.SendMessageParams{
 				Text:   "You are not allowed to use this",
-				ChatID: chatID,
+				ChatID: sender.chatID,
 			})
 			if err != nil {
 				whitelist.logger.Error(err.Error())
@@ -56,6 +158,38 @@ func WithWhitelist(whitelist *WhitelistMiddleware, handler bot.HandlerFunc) bot.
 			return
 		}
 
-		handler(ctx, b, update)
+		role := whitelist.GetUserRole(sender.userID)
+		if event := eventOf(update); !auth.Can(role, event) {
+			whitelist.logger.Warn(fmt.Sprintf("user %s (ID %d) with role %s is not permitted to trigger '%s'", sender.username, sender.userID, role, event))
+
+			_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+				Text:   "Your role does not permit this action",
+				ChatID: sender.chatID,
+			})
+			if err != nil {
+				whitelist.logger.Error(err.Error())
+			}
+
+			return
+		}
+
+		handler(auth.WithRole(ctx, role), b, update)
+	}
+}
+
+func (wm *WhitelistMiddleware) alertOwners(ctx context.Context, b *bot.Bot, intruder Intruder) {
+	alertFormat := "Unauthorized access: user @%s (ID %d) made %d attempts, silenced until %s.\nLast message: %s"
+	alert := fmt.Sprintf(alertFormat, intruder.Username, intruder.UserID, intruder.Attempts, intruder.SilencedUntil.Format(time.DateTime), intruder.LastMessage)
+
+	for userID, role := range wm.ListUsers() {
+		if role != auth.ROLE_OWNER {
+			continue
+		}
+		if _, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			Text:   alert,
+			ChatID: userID,
+		}); err != nil {
+			wm.logger.Error(err.Error())
+		}
 	}
 }
@@ -0,0 +1,146 @@
+// Package preset keeps templates of the create user wizard.
+package preset
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luckyComet55/marzban-tg-bot/internal/validation"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/storage"
+)
+
+const namePattern = "^[a-zA-Z0-9_-]{1,32}$"
+
+var nameRegexp = regexp.MustCompile(namePattern)
+
+var ErrPresetNotFound = errors.New("template not found")
+
+// Preset is a saved shape of new users. Zero DataLimit means no limit.
+// Expire is kept as entered, like "30d", so that it is counted from the
+// moment a user is created. Empty Expire means users never expire.
+type Preset struct {
+	Name       string   `json:"name"`
+	ProxyNames []string `json:"proxies"`
+	DataLimit  int64    `json:"data_limit"`
+	Expire     string   `json:"expire,omitempty"`
+}
+
+// ExpireAt is the expiry of a user created from the preset at now.
+// It fails once a preset with a fixed date is past that date.
+func (p Preset) ExpireAt(now time.Time) (time.Time, error) {
+	return validation.Expiry(p.Expire, now)
+}
+
+// Summary describes the preset the way it is entered, like "5GB/7d, VLESS".
+func (p Preset) Summary() string {
+	limit := "unlimited"
+	switch {
+	case p.DataLimit == 0:
+	case p.DataLimit%(1<<30) == 0:
+		limit = fmt.Sprintf("%dGB", p.DataLimit>>30)
+	case p.DataLimit%(1<<20) == 0:
+		limit = fmt.Sprintf("%dMB", p.DataLimit>>20)
+	default:
+		limit = fmt.Sprintf("%dB", p.DataLimit)
+	}
+	expire := "never"
+	if p.Expire != "" {
+		expire = p.Expire
+	}
+	return fmt.Sprintf("%s/%s, %s", limit, expire, strings.Join(p.ProxyNames, "+"))
+}
+
+func ValidateName(name string) error {
+	if !nameRegexp.MatchString(name) {
+		return fmt.Errorf("template name '%s' does not match pattern %s", name, namePattern)
+	}
+	return nil
+}
+
+type Store struct {
+	store   *storage.JSONFile[map[string]Preset]
+	presets map[string]Preset
+	mu      sync.RWMutex
+}
+
+func NewStore(store *storage.JSONFile[map[string]Preset]) (*Store, error) {
+	presets, _, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if presets == nil {
+		presets = make(map[string]Preset)
+	}
+
+	return &Store{
+		store:   store,
+		presets: presets,
+	}, nil
+}
+
+// List returns presets sorted by name.
+func (s *Store) List() []Preset {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	presets := slices.Collect(maps.Values(s.presets))
+	slices.SortFunc(presets, func(a, b Preset) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return presets
+}
+
+func (s *Store) Get(name string) (Preset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	preset, ok := s.presets[name]
+	if !ok {
+		return Preset{}, fmt.Errorf("%w: %s", ErrPresetNotFound, name)
+	}
+	return preset, nil
+}
+
+// Save adds the preset or replaces the one with the same name.
+func (s *Store) Save(preset Preset) error {
+	if err := ValidateName(preset.Name); err != nil {
+		return err
+	}
+	if _, err := validation.Expiry(preset.Expire, time.Now()); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := maps.Clone(s.presets)
+	updated[preset.Name] = preset
+	if err := s.store.Save(updated); err != nil {
+		return err
+	}
+	s.presets = updated
+	return nil
+}
+
+func (s *Store) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.presets[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrPresetNotFound, name)
+	}
+
+	updated := maps.Clone(s.presets)
+	delete(updated, name)
+	if err := s.store.Save(updated); err != nil {
+		return err
+	}
+	s.presets = updated
+	return nil
+}
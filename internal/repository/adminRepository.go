@@ -9,11 +9,63 @@ import (
 type AdminState int
 
 const (
-	ADMIN_STATE_DEFAULT                  fsm.State = "DEFAULT"
-	ADMIN_STATE_SELECT_ACTION            fsm.State = "SELECT_ACTION"
-	ADMIN_STATE_CREATE_USER_INPUT_NAME   fsm.State = "STATE_CREATE_USER_INPUT_NAME"
-	ADMIN_STATE_CREATE_USER_SELECT_PROXY fsm.State = "CREATE_USER_SELECT_PROXY"
-	ADMIN_STATE_CREATE_USER_SUBMIT_DATA  fsm.State = "CREATE_USER_SUBMIT_DATA"
+	ADMIN_STATE_DEFAULT                     fsm.State = "DEFAULT"
+	ADMIN_STATE_SELECT_ACTION               fsm.State = "SELECT_ACTION"
+	ADMIN_STATE_CREATE_USER_SELECT_TEMPLATE fsm.State = "CREATE_USER_SELECT_TEMPLATE"
+	ADMIN_STATE_CREATE_USER_INPUT_NAME      fsm.State = "STATE_CREATE_USER_INPUT_NAME"
+	ADMIN_STATE_CREATE_USER_SELECT_PROXY    fsm.State = "CREATE_USER_SELECT_PROXY"
+	ADMIN_STATE_CREATE_USER_META            fsm.State = "CREATE_USER_META"
+	ADMIN_STATE_CREATE_USER_SUBMIT_DATA     fsm.State = "CREATE_USER_SUBMIT_DATA"
+	ADMIN_STATE_USER_DETAILS                fsm.State = "USER_DETAILS"
+	ADMIN_STATE_LIST_USERS                  fsm.State = "LIST_USERS"
+	ADMIN_STATE_LIST_PROXIES                fsm.State = "LIST_PROXIES"
+	ADMIN_STATE_FIND_USER_INPUT_QUERY       fsm.State = "FIND_USER_INPUT_QUERY"
+	ADMIN_STATE_BULK_CREATE_UPLOAD          fsm.State = "BULK_CREATE_UPLOAD"
+	ADMIN_STATE_BULK_CREATE_CONFIRM         fsm.State = "BULK_CREATE_CONFIRM"
+	ADMIN_STATE_EXPORT_USERS                fsm.State = "EXPORT_USERS"
+	ADMIN_STATE_USER_META_INPUT             fsm.State = "USER_META_INPUT"
+)
+
+const (
+	ADMIN_EVENT_NEXT          fsm.Event = "next"
+	ADMIN_EVENT_CANCEL        fsm.Event = "cnl"
+	ADMIN_EVENT_LIST_USERS    fsm.Event = "lu"
+	ADMIN_EVENT_LIST_PROXIES  fsm.Event = "lp"
+	ADMIN_EVENT_CREATE_USER   fsm.Event = "cu"
+	ADMIN_EVENT_USE_TEMPLATE  fsm.Event = "tp"
+	ADMIN_EVENT_USE_PROXY     fsm.Event = "up"
+	ADMIN_EVENT_SUBMIT_CREATE fsm.Event = "s"
+	ADMIN_EVENT_USER_DETAILS  fsm.Event = "ud"
+	ADMIN_EVENT_BACK          fsm.Event = "bk"
+	ADMIN_EVENT_PAGE          fsm.Event = "pg"
+	ADMIN_EVENT_SORT          fsm.Event = "so"
+	ADMIN_EVENT_FIND_USER     fsm.Event = "fu"
+	ADMIN_EVENT_CONFIRM_BULK  fsm.Event = "bcf"
+	ADMIN_EVENT_QR_CODES      fsm.Event = "qr"
+	ADMIN_EVENT_CLIENT_CONFIG fsm.Event = "cc"
+	ADMIN_EVENT_BULK_CREATE   fsm.Event = "bc"
+	ADMIN_EVENT_EXPORT_FORMAT fsm.Event = "xf"
+	ADMIN_EVENT_EXPORT_COLUMN fsm.Event = "xc"
+	ADMIN_EVENT_EXPORT        fsm.Event = "xd"
+	ADMIN_EVENT_USER_META     fsm.Event = "mt"
+	ADMIN_EVENT_SKIP          fsm.Event = "sk"
+)
+
+// Bot commands are checked against admin roles the same way as FSM events.
+const (
+	ADMIN_COMMAND_START        fsm.Event = "/start"
+	ADMIN_COMMAND_CANCEL       fsm.Event = "/cancel"
+	ADMIN_COMMAND_ADMINS       fsm.Event = "/admins"
+	ADMIN_COMMAND_ADMIN_ADD    fsm.Event = "/admin_add"
+	ADMIN_COMMAND_ADMIN_REMOVE fsm.Event = "/admin_remove"
+	ADMIN_COMMAND_INVITE       fsm.Event = "/invite"
+	ADMIN_COMMAND_INTRUDERS    fsm.Event = "/intruders"
+	ADMIN_COMMAND_TOTP_ENROLL  fsm.Event = "/totp_enroll"
+	ADMIN_COMMAND_TOTP_CONFIRM fsm.Event = "/totp_confirm"
+	ADMIN_COMMAND_TOTP_DISABLE fsm.Event = "/totp_disable"
+	ADMIN_COMMAND_EXPORT       fsm.Event = "/export"
+	ADMIN_COMMAND_TEMPLATES    fsm.Event = "/templates"
+	ADMIN_COMMAND_META_RENAME  fsm.Event = "/meta_rename"
 )
 
 type AdminRepository interface {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"strings"
 
 	pcl "github.com/luckyComet55/marzban-proto-contract/gen/go/contract"
 	"google.golang.org/protobuf/types/known/emptypb"
@@ -12,6 +13,14 @@ import (
 
 type ProxyData struct {
 	ProxyName string
+	Protocol  string
+}
+
+func proxyDataFromInfo(proxy *pcl.ProxyProtocolInfo) ProxyData {
+	return ProxyData{
+		ProxyName: proxy.ProxyName,
+		Protocol:  strings.ToLower(proxy.ProtocolType.String()),
+	}
 }
 
 type ProxyRepository interface {
@@ -46,9 +55,7 @@ func (pr *proxyRepository) ListProxies() ([]ProxyData, error) {
 			pr.logger.Error(err.Error(), "method", "ListProxies")
 			return nil, fmt.Errorf("Unexpected error, try again later")
 		}
-		proxies = append(proxies, ProxyData{
-			ProxyName: proxy.ProxyName,
-		})
+		proxies = append(proxies, proxyDataFromInfo(proxy))
 	}
 	return proxies, nil
 }
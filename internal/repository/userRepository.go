@@ -2,9 +2,12 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"sync"
+	"time"
 
 	pcl "github.com/luckyComet55/marzban-proto-contract/gen/go/contract"
 	"google.golang.org/grpc/codes"
@@ -12,24 +15,63 @@ import (
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
+// UserCreateData describes a new user. Zero DataLimit means
+// no traffic limit and zero ExpireAt means the user never expires.
 type UserCreateData struct {
-	Username      string
-	ProxyProtocol string
+	Username   string
+	ProxyNames []string
+	DataLimit  int64
+	ExpireAt   time.Time
+	Note       string
 }
 
+type UserStatus string
+
+const (
+	USER_STATUS_ACTIVE   UserStatus = "active"
+	USER_STATUS_DISABLED UserStatus = "disabled"
+	USER_STATUS_ON_HOLD  UserStatus = "on_hold"
+	USER_STATUS_LIMITED  UserStatus = "limited"
+	USER_STATUS_EXPIRED  UserStatus = "expired"
+)
+
 type UserData struct {
 	UserCreateData
+	Status      UserStatus
 	UsedTraffic int64
-	ConfigUrl   string
+	ConfigUrls  []string
+	Proxies     []ProxyData
 }
 
+// UserOperation is a change of users the management server may not
+// implement. The bot offers only the operations the repository supports.
+type UserOperation string
+
+const (
+	// USER_OPERATION_CREATE_LIMITS is creating users with a data limit,
+	// an expiry or a note.
+	USER_OPERATION_CREATE_LIMITS UserOperation = "create_limits"
+	// USER_OPERATION_CREATE_MULTI_PROXY is creating users with several proxies.
+	USER_OPERATION_CREATE_MULTI_PROXY UserOperation = "create_multi_proxy"
+)
+
 type UserShortData struct {
 	Username string
 }
 
+const detailsConcurrency = 8
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrNotSupported = errors.New("not supported by the management server")
+)
+
 type UserRepository interface {
 	GetUsers() ([]UserShortData, error)
+	GetUsersDetails() ([]UserData, error)
+	GetUser(username string) (UserData, error)
 	CreateUser(user UserCreateData) (UserData, error)
+	Supports(operation UserOperation) bool
 }
 
 type userRepository struct {
@@ -60,10 +102,79 @@ func (repo *userRepository) GetUsers() ([]UserShortData, error) {
 	return users, nil
 }
 
+// GetUsersDetails fetches every user with GetUser, as the contract
+// lists usernames only. Requests run with bounded concurrency.
+func (repo *userRepository) GetUsersDetails() ([]UserData, error) {
+	users, err := repo.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]UserData, len(users))
+	errs := make([]error, len(users))
+	sem := make(chan struct{}, detailsConcurrency)
+	var wg sync.WaitGroup
+	for i, user := range users {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			details[i], errs[i] = repo.GetUser(user.Username)
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return details, nil
+}
+
+func (repo *userRepository) GetUser(username string) (UserData, error) {
+	userInfo, err := repo.client.GetUser(context.Background(), &pcl.UserShortInfo{
+		Username: username,
+	})
+	if err != nil {
+		repo.logger.Error(err.Error(), "method", "GetUser")
+		if status.Code(err) == codes.NotFound {
+			return UserData{}, fmt.Errorf("%w: %s", ErrUserNotFound, username)
+		}
+		return UserData{}, fmt.Errorf("Some error occured, try again later")
+	}
+
+	return userDataFromInfo(userInfo), nil
+}
+
+func userDataFromInfo(userInfo *pcl.UserInfo) UserData {
+	proxies := make([]ProxyData, 0, len(userInfo.ProxyProtocol))
+	for _, proxy := range userInfo.ProxyProtocol {
+		proxies = append(proxies, proxyDataFromInfo(proxy))
+	}
+
+	return UserData{
+		UserCreateData: UserCreateData{
+			Username: userInfo.Username,
+		},
+		Status:      UserStatus(userInfo.Status),
+		UsedTraffic: int64(userInfo.UsedTraffic),
+		ConfigUrls:  userInfo.ConfigUrls,
+		Proxies:     proxies,
+	}
+}
+
 func (repo *userRepository) CreateUser(user UserCreateData) (UserData, error) {
+	if user.DataLimit != 0 || !user.ExpireAt.IsZero() || user.Note != "" {
+		return UserData{}, fmt.Errorf("creating user %s with data limit, expiry or note: %w", user.Username, ErrNotSupported)
+	}
+	// The contract carries a single proxy per new user.
+	if len(user.ProxyNames) != 1 {
+		return UserData{}, fmt.Errorf("creating user %s with %d proxies: %w", user.Username, len(user.ProxyNames), ErrNotSupported)
+	}
+
 	userData, err := repo.client.CreateUser(context.Background(), &pcl.CreateUserInfo{
 		Username:      user.Username,
-		ProxyProtocol: user.ProxyProtocol,
+		ProxyProtocol: user.ProxyNames[0],
 	})
 	if err != nil {
 		repo.logger.Error(err.Error())
@@ -75,17 +186,17 @@ func (repo *userRepository) CreateUser(user UserCreateData) (UserData, error) {
 		}
 		return UserData{}, userError
 	}
-	userMappedData := UserData{
-		UserCreateData: UserCreateData{
-			userData.Username,
-			userData.Status,
-		},
-		UsedTraffic: int64(userData.UsedTraffic),
-		ConfigUrl:   userData.ConfigUrls[0],
-	}
+	userMappedData := userDataFromInfo(userData)
+	userMappedData.ProxyNames = user.ProxyNames
 	return userMappedData, nil
 }
 
+// Supports reports false for every operation, as the management contract
+// can only list, get and create users so far.
+func (repo *userRepository) Supports(operation UserOperation) bool {
+	return false
+}
+
 func NewUserRepository(client pcl.MarzbanManagementPanelClient, logger *slog.Logger) UserRepository {
 	return &userRepository{
 		client: client,
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// memoryUserRepository keeps users in memory instead of the Marzban panel.
+// It is meant to stand in for the gRPC backed repository in tests.
+type memoryUserRepository struct {
+	users map[string]UserData
+	mu    sync.RWMutex
+}
+
+func NewMemoryUserRepository(users ...UserData) UserRepository {
+	repo := &memoryUserRepository{
+		users: make(map[string]UserData, len(users)),
+	}
+	for _, user := range users {
+		repo.users[user.Username] = user
+	}
+	return repo
+}
+
+func (repo *memoryUserRepository) Supports(operation UserOperation) bool {
+	return true
+}
+
+func (repo *memoryUserRepository) GetUsers() ([]UserShortData, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	users := make([]UserShortData, 0, len(repo.users))
+	for _, user := range repo.users {
+		users = append(users, UserShortData{
+			Username: user.Username,
+		})
+	}
+	slices.SortFunc(users, func(a, b UserShortData) int {
+		return strings.Compare(a.Username, b.Username)
+	})
+	return users, nil
+}
+
+func (repo *memoryUserRepository) GetUsersDetails() ([]UserData, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	users := make([]UserData, 0, len(repo.users))
+	for _, user := range repo.users {
+		users = append(users, user)
+	}
+	slices.SortFunc(users, func(a, b UserData) int {
+		return strings.Compare(a.Username, b.Username)
+	})
+	return users, nil
+}
+
+func (repo *memoryUserRepository) GetUser(username string) (UserData, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	user, ok := repo.users[username]
+	if !ok {
+		return UserData{}, fmt.Errorf("%w: %s", ErrUserNotFound, username)
+	}
+	return user, nil
+}
+
+func (repo *memoryUserRepository) CreateUser(user UserCreateData) (UserData, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.users[user.Username]; ok {
+		return UserData{}, fmt.Errorf("user '%s' already exists", user.Username)
+	}
+
+	proxies := make([]ProxyData, 0, len(user.ProxyNames))
+	for _, name := range user.ProxyNames {
+		proxies = append(proxies, ProxyData{ProxyName: name})
+	}
+	userData := UserData{
+		UserCreateData: user,
+		Status:         USER_STATUS_ACTIVE,
+		Proxies:        proxies,
+	}
+	repo.users[user.Username] = userData
+	return userData, nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func testUsers() []UserData {
+	return []UserData{
+		{
+			UserCreateData: UserCreateData{Username: "bob", ProxyNames: []string{"vless"}, DataLimit: 10 << 30},
+			Status:         USER_STATUS_DISABLED,
+			UsedTraffic:    5 << 30,
+			ConfigUrls:     []string{"vless://bob@example.com:443/abcdef#main"},
+			Proxies:        []ProxyData{{ProxyName: "vless"}},
+		},
+		{
+			UserCreateData: UserCreateData{Username: "alice", ProxyNames: []string{"vmess"}},
+			Status:         USER_STATUS_ACTIVE,
+			Proxies:        []ProxyData{{ProxyName: "vmess"}},
+		},
+	}
+}
+
+func TestMemoryGetUser(t *testing.T) {
+	userRepository := NewMemoryUserRepository(testUsers()...)
+
+	tests := []struct {
+		name     string
+		username string
+		status   UserStatus
+		err      error
+	}{
+		{name: "existing", username: "alice", status: USER_STATUS_ACTIVE},
+		{name: "other existing", username: "bob", status: USER_STATUS_DISABLED},
+		{name: "missing", username: "carol", err: ErrUserNotFound},
+		{name: "case sensitive", username: "Alice", err: ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := userRepository.GetUser(tt.username)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("GetUser(%q) error = %v, want %v", tt.username, err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if user.Username != tt.username || user.Status != tt.status {
+				t.Errorf("GetUser(%q) = %s %s, want %s %s", tt.username, user.Username, user.Status, tt.username, tt.status)
+			}
+		})
+	}
+}
+
+func TestMemoryListsSortedByName(t *testing.T) {
+	userRepository := NewMemoryUserRepository(testUsers()...)
+
+	short, err := userRepository.GetUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	details, err := userRepository.GetUsersDetails()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"alice", "bob"}
+	for _, got := range [][]string{
+		usernamesOf(short, func(u UserShortData) string { return u.Username }),
+		usernamesOf(details, func(u UserData) string { return u.Username }),
+	} {
+		if !slices.Equal(got, want) {
+			t.Errorf("usernames = %v, want %v", got, want)
+		}
+	}
+}
+
+func usernamesOf[T any](users []T, username func(T) string) []string {
+	names := make([]string, 0, len(users))
+	for _, u := range users {
+		names = append(names, username(u))
+	}
+	return names
+}
+
+func TestMemoryCreate(t *testing.T) {
+	userRepository := NewMemoryUserRepository(testUsers()...)
+
+	created, err := userRepository.CreateUser(UserCreateData{Username: "carol", ProxyNames: []string{"trojan"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Status != USER_STATUS_ACTIVE || len(created.Proxies) != 1 {
+		t.Errorf("created user = %+v, want an active user with one proxy", created)
+	}
+	if _, err := userRepository.GetUser("carol"); err != nil {
+		t.Errorf("GetUser after create error = %v", err)
+	}
+	if _, err := userRepository.CreateUser(UserCreateData{Username: "bob"}); err == nil {
+		t.Error("creating an existing user succeeded")
+	}
+}
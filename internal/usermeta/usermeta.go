@@ -0,0 +1,200 @@
+// Package usermeta keeps bot-side notes, contacts and tags of panel users.
+package usermeta
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/luckyComet55/marzban-tg-bot/internal/validation"
+	"github.com/luckyComet55/marzban-tg-bot/pkg/storage"
+)
+
+var (
+	ErrMetaNotFound = errors.New("user has no notes or tags")
+	ErrMetaExists   = errors.New("user already has notes or tags")
+)
+
+type Meta struct {
+	Note    string   `json:"note,omitempty"`
+	Contact string   `json:"contact,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+func (m Meta) IsZero() bool {
+	return m.Note == "" && m.Contact == "" && len(m.Tags) == 0
+}
+
+func (m Meta) HasTag(tag string) bool {
+	return slices.Contains(m.Tags, tag)
+}
+
+// File is what the store keeps on disk. Metadata is keyed by a stable
+// ID and usernames only point to it, so a rename moves a single entry.
+type File struct {
+	Users map[string]Meta   `json:"users"`
+	Names map[string]string `json:"names"`
+}
+
+type Store struct {
+	store *storage.JSONFile[File]
+	file  File
+	mu    sync.RWMutex
+}
+
+func NewStore(store *storage.JSONFile[File]) (*Store, error) {
+	file, _, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if file.Users == nil {
+		file.Users = make(map[string]Meta)
+	}
+	if file.Names == nil {
+		file.Names = make(map[string]string)
+	}
+
+	return &Store{
+		store: store,
+		file:  file,
+	}, nil
+}
+
+// Get returns the metadata of the user, which is empty for unknown users.
+func (s *Store) Get(username string) Meta {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.file.Users[s.file.Names[username]]
+}
+
+// Set replaces the metadata of the user. Empty metadata forgets the user.
+func (s *Store) Set(username string, meta Meta) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated := s.clone()
+	id, ok := updated.Names[username]
+	switch {
+	case meta.IsZero():
+		delete(updated.Users, id)
+		delete(updated.Names, username)
+	case !ok:
+		raw := make([]byte, 8)
+		if _, err := rand.Read(raw); err != nil {
+			return err
+		}
+		id = hex.EncodeToString(raw)
+		updated.Names[username] = id
+		fallthrough
+	default:
+		updated.Users[id] = meta
+	}
+	return s.save(updated)
+}
+
+// Rename moves the metadata to the new username of a renamed user.
+func (s *Store) Rename(oldUsername, newUsername string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.file.Names[oldUsername]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrMetaNotFound, oldUsername)
+	}
+	if _, ok := s.file.Names[newUsername]; ok {
+		return fmt.Errorf("%w: %s", ErrMetaExists, newUsername)
+	}
+
+	updated := s.clone()
+	delete(updated.Names, oldUsername)
+	updated.Names[newUsername] = id
+	return s.save(updated)
+}
+
+// Tagged returns usernames of users with the tag.
+func (s *Store) Tagged(tag string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	usernames := make([]string, 0)
+	for username, id := range s.file.Names {
+		if s.file.Users[id].HasTag(tag) {
+			usernames = append(usernames, username)
+		}
+	}
+	slices.Sort(usernames)
+	return usernames
+}
+
+func (s *Store) clone() File {
+	return File{
+		Users: maps.Clone(s.file.Users),
+		Names: maps.Clone(s.file.Names),
+	}
+}
+
+func (s *Store) save(updated File) error {
+	if err := s.store.Save(updated); err != nil {
+		return err
+	}
+	s.file = updated
+	return nil
+}
+
+// ParseInput applies lines like "tags: team-a, paid", "contact: @alice"
+// or "note: pays in cash" to the metadata. A "-" value clears the field.
+func ParseInput(input string, meta Meta) (Meta, error) {
+	for _, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return Meta{}, fmt.Errorf("line '%s' must look like key: value", line)
+		}
+		value = strings.TrimSpace(value)
+		if value == "-" {
+			value = ""
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "tags":
+			tags := make([]string, 0)
+			for _, tag := range strings.Split(value, ",") {
+				tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+				if tag == "" {
+					continue
+				}
+				if err := validation.Tag(tag); err != nil {
+					return Meta{}, err
+				}
+				if !slices.Contains(tags, tag) {
+					tags = append(tags, tag)
+				}
+			}
+			meta.Tags = tags
+		case "contact":
+			if value != "" {
+				if err := validation.Contact(value); err != nil {
+					return Meta{}, err
+				}
+			}
+			meta.Contact = value
+		case "note":
+			if err := validation.Note(value); err != nil {
+				return Meta{}, err
+			}
+			meta.Note = value
+		default:
+			return Meta{}, fmt.Errorf("unknown key '%s', use tags, contact or note", key)
+		}
+	}
+	return meta, nil
+}
@@ -0,0 +1,121 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	usernamePattern = "^[a-zA-Z0-9_]{3,32}$"
+	tagPattern      = "^[a-z0-9_-]{1,32}$"
+	contactPattern  = "^(@[a-zA-Z0-9_]{5,32}|[0-9]{1,20})$"
+	noteMaxLength   = 500
+)
+
+var (
+	usernameRegexp = regexp.MustCompile(usernamePattern)
+	tagRegexp      = regexp.MustCompile(tagPattern)
+	contactRegexp  = regexp.MustCompile(contactPattern)
+)
+
+var dataUnits = map[string]int64{
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+}
+
+var dataLimitRegexp = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([KMGT]?B)$`)
+
+// Username checks that the name is 3-32 symbols of [a-zA-Z0-9_].
+func Username(username string) error {
+	if !usernameRegexp.MatchString(username) {
+		return fmt.Errorf("username '%s' does not match pattern %s", username, usernamePattern)
+	}
+	return nil
+}
+
+// Note checks that a user note fits into the panel's note field.
+func Note(note string) error {
+	if n := utf8.RuneCountInString(note); n > noteMaxLength {
+		return fmt.Errorf("note is %d symbols long, at most %d allowed", n, noteMaxLength)
+	}
+	return nil
+}
+
+// Tag checks that a tag is 1-32 symbols of [a-z0-9_-].
+func Tag(tag string) error {
+	if !tagRegexp.MatchString(tag) {
+		return fmt.Errorf("tag '%s' does not match pattern %s", tag, tagPattern)
+	}
+	return nil
+}
+
+// Contact checks that a Telegram contact is a @username or a numeric ID.
+func Contact(contact string) error {
+	if !contactRegexp.MatchString(contact) {
+		return fmt.Errorf("contact '%s' must be a Telegram @username or user ID", contact)
+	}
+	return nil
+}
+
+// DataLimit parses limits like "25GB" or "500 MB" into bytes.
+// "unlimited" and "0" mean no limit and are returned as 0.
+func DataLimit(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "UNLIMITED" || value == "0" {
+		return 0, nil
+	}
+
+	match := dataLimitRegexp.FindStringSubmatch(value)
+	if match == nil {
+		return 0, fmt.Errorf("data limit '%s' must look like 25GB, 500MB or unlimited", value)
+	}
+
+	amount, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("data limit '%s': %w", value, err)
+	}
+	bytes := amount * float64(dataUnits[match[2]])
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("data limit '%s' is too large", value)
+	}
+	limit := int64(bytes)
+	if limit <= 0 {
+		return 0, errors.New("data limit must be positive")
+	}
+	return limit, nil
+}
+
+// Expiry parses an expiry date given as YYYY-MM-DD or as a number of days
+// from now like "30d". "never" means no expiry and is returned as zero time.
+func Expiry(value string, now time.Time) (time.Time, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "never" || value == "" {
+		return time.Time{}, nil
+	}
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return time.Time{}, fmt.Errorf("expiry '%s' must be a positive number of days like 30d", value)
+		}
+		return now.AddDate(0, 0, n), nil
+	}
+
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expiry '%s' must look like 2025-12-31, 30d or never", value)
+	}
+	if !date.After(now) {
+		return time.Time{}, fmt.Errorf("expiry %s is in the past", value)
+	}
+	return date, nil
+}
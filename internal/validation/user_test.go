@@ -0,0 +1,149 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		valid    bool
+	}{
+		{"alice", true},
+		{"Bob_42", true},
+		{"abc", true},
+		{strings.Repeat("a", 32), true},
+		{"ab", false},
+		{strings.Repeat("a", 33), false},
+		{"alice-1", false},
+		{"alice bob", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if err := Username(tt.username); (err == nil) != tt.valid {
+			t.Errorf("Username(%q) error = %v, want valid %t", tt.username, err, tt.valid)
+		}
+	}
+}
+
+func TestNote(t *testing.T) {
+	tests := []struct {
+		note  string
+		valid bool
+	}{
+		{"", true},
+		{"paid until March", true},
+		{strings.Repeat("я", noteMaxLength), true},
+		{strings.Repeat("a", noteMaxLength+1), false},
+	}
+	for _, tt := range tests {
+		if err := Note(tt.note); (err == nil) != tt.valid {
+			t.Errorf("Note of %d symbols error = %v, want valid %t", len([]rune(tt.note)), err, tt.valid)
+		}
+	}
+}
+
+func TestTag(t *testing.T) {
+	tests := []struct {
+		tag   string
+		valid bool
+	}{
+		{"team-a", true},
+		{"vip_2", true},
+		{"Team", false},
+		{"", false},
+		{"a b", false},
+		{strings.Repeat("a", 33), false},
+	}
+	for _, tt := range tests {
+		if err := Tag(tt.tag); (err == nil) != tt.valid {
+			t.Errorf("Tag(%q) error = %v, want valid %t", tt.tag, err, tt.valid)
+		}
+	}
+}
+
+func TestContact(t *testing.T) {
+	tests := []struct {
+		contact string
+		valid   bool
+	}{
+		{"@alice_b", true},
+		{"123456789", true},
+		{"@abc", false},
+		{"alice_b", false},
+		{"+123", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if err := Contact(tt.contact); (err == nil) != tt.valid {
+			t.Errorf("Contact(%q) error = %v, want valid %t", tt.contact, err, tt.valid)
+		}
+	}
+}
+
+func TestDataLimit(t *testing.T) {
+	tests := []struct {
+		value string
+		limit int64
+		valid bool
+	}{
+		{"unlimited", 0, true},
+		{"Unlimited", 0, true},
+		{"0", 0, true},
+		{"25GB", 25 << 30, true},
+		{"500 mb", 500 << 20, true},
+		{"1.5KB", 1536, true},
+		{"1TB", 1 << 40, true},
+		{"10", 0, false},
+		{"GB", 0, false},
+		{"-5GB", 0, false},
+		{"0GB", 0, false},
+		{"9999999TB", 0, false},
+		{"5PB", 0, false},
+	}
+	for _, tt := range tests {
+		limit, err := DataLimit(tt.value)
+		if (err == nil) != tt.valid {
+			t.Errorf("DataLimit(%q) error = %v, want valid %t", tt.value, err, tt.valid)
+			continue
+		}
+		if limit != tt.limit {
+			t.Errorf("DataLimit(%q) = %d, want %d", tt.value, limit, tt.limit)
+		}
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value  string
+		expire time.Time
+		valid  bool
+	}{
+		{"never", time.Time{}, true},
+		{"", time.Time{}, true},
+		{" Never ", time.Time{}, true},
+		{"30d", now.AddDate(0, 0, 30), true},
+		{"1D", now.AddDate(0, 0, 1), true},
+		{"2025-12-31", time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC), true},
+		{"0d", time.Time{}, false},
+		{"-3d", time.Time{}, false},
+		{"xd", time.Time{}, false},
+		{"2025-06-15", time.Time{}, false},
+		{"2024-01-01", time.Time{}, false},
+		{"31.12.2025", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		expire, err := Expiry(tt.value, now)
+		if (err == nil) != tt.valid {
+			t.Errorf("Expiry(%q) error = %v, want valid %t", tt.value, err, tt.valid)
+			continue
+		}
+		if !expire.Equal(tt.expire) {
+			t.Errorf("Expiry(%q) = %s, want %s", tt.value, expire, tt.expire)
+		}
+	}
+}
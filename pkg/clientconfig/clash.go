@@ -0,0 +1,104 @@
+package clientconfig
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// Clash builds a Clash/Mihomo config with every outbound
+// in a single select group.
+func Clash(outbounds []Outbound) ([]byte, error) {
+	names := make([]string, 0, len(outbounds))
+	proxies := make([]map[string]any, 0, len(outbounds))
+	for _, o := range outbounds {
+		names = append(names, o.Tag)
+		proxies = append(proxies, clashProxy(o))
+	}
+
+	config := map[string]any{
+		"mixed-port": 7890,
+		"mode":       "rule",
+		"log-level":  "warning",
+		"proxies":    proxies,
+		"proxy-groups": []map[string]any{{
+			"name":    "proxy",
+			"type":    "select",
+			"proxies": names,
+		}},
+		"rules": []string{"MATCH,proxy"},
+	}
+	return yaml.Marshal(config)
+}
+
+func clashProxy(o Outbound) map[string]any {
+	proxy := map[string]any{
+		"name":   o.Tag,
+		"server": o.Server,
+		"port":   o.Port,
+		"udp":    true,
+	}
+
+	switch o.Protocol {
+	case PROTOCOL_VLESS:
+		proxy["type"] = "vless"
+		proxy["uuid"] = o.UUID
+		if o.Flow != "" {
+			proxy["flow"] = o.Flow
+		}
+	case PROTOCOL_VMESS:
+		proxy["type"] = "vmess"
+		proxy["uuid"] = o.UUID
+		proxy["alterId"] = o.AlterID
+		proxy["cipher"] = o.Cipher
+	case PROTOCOL_TROJAN:
+		proxy["type"] = "trojan"
+		proxy["password"] = o.Password
+	case PROTOCOL_SHADOWSOCKS:
+		proxy["type"] = "ss"
+		proxy["cipher"] = o.Method
+		proxy["password"] = o.Password
+	}
+
+	if o.Security == SECURITY_TLS || o.Security == SECURITY_REALITY {
+		// Trojan is always over TLS in Clash and names the server with sni.
+		serverName := firstNonEmpty(o.SNI, o.Host, o.Server)
+		if o.Protocol == PROTOCOL_TROJAN {
+			proxy["sni"] = serverName
+		} else {
+			proxy["tls"] = true
+			proxy["servername"] = serverName
+		}
+		if len(o.ALPN) > 0 {
+			proxy["alpn"] = o.ALPN
+		}
+		if o.Fingerprint != "" {
+			proxy["client-fingerprint"] = o.Fingerprint
+		}
+		if o.Security == SECURITY_REALITY {
+			proxy["reality-opts"] = map[string]any{
+				"public-key": o.PublicKey,
+				"short-id":   o.ShortID,
+			}
+		}
+	}
+
+	switch o.Network {
+	case "ws":
+		proxy["network"] = "ws"
+		opts := map[string]any{"path": o.Path}
+		if o.Host != "" {
+			opts["headers"] = map[string]any{"Host": o.Host}
+		}
+		proxy["ws-opts"] = opts
+	case "grpc":
+		proxy["network"] = "grpc"
+		proxy["grpc-opts"] = map[string]any{"grpc-service-name": o.ServiceName}
+	case "http", "h2":
+		proxy["network"] = "h2"
+		opts := map[string]any{"path": o.Path}
+		if o.Host != "" {
+			opts["host"] = []string{o.Host}
+		}
+		proxy["h2-opts"] = opts
+	}
+	return proxy
+}
@@ -0,0 +1,285 @@
+// Package clientconfig converts proxy share links into configuration
+// files of popular client apps.
+package clientconfig
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var ErrUnsupportedLink = errors.New("unsupported share link")
+
+const (
+	PROTOCOL_VLESS       = "vless"
+	PROTOCOL_VMESS       = "vmess"
+	PROTOCOL_TROJAN      = "trojan"
+	PROTOCOL_SHADOWSOCKS = "shadowsocks"
+)
+
+const (
+	SECURITY_NONE    = "none"
+	SECURITY_TLS     = "tls"
+	SECURITY_REALITY = "reality"
+)
+
+// Outbound is a proxy server described by a share link.
+type Outbound struct {
+	Tag      string
+	Protocol string
+	Server   string
+	Port     int
+
+	// UUID authenticates VLESS and VMess, Password authenticates
+	// Trojan and Shadowsocks.
+	UUID     string
+	Password string
+	// Method is the Shadowsocks cipher, Cipher is the VMess one.
+	Method  string
+	Cipher  string
+	AlterID int
+	Flow    string
+
+	Security    string
+	SNI         string
+	Fingerprint string
+	ALPN        []string
+	PublicKey   string
+	ShortID     string
+
+	// Network is the transport: tcp, ws, grpc or http.
+	Network     string
+	Path        string
+	Host        string
+	ServiceName string
+}
+
+// Parse decodes a vless://, vmess://, trojan:// or ss:// share link.
+func Parse(link string) (Outbound, error) {
+	scheme, _, ok := strings.Cut(link, "://")
+	if !ok {
+		return Outbound{}, fmt.Errorf("%w: %s", ErrUnsupportedLink, link)
+	}
+
+	switch strings.ToLower(scheme) {
+	case "vless":
+		return parseURLLink(link, PROTOCOL_VLESS)
+	case "trojan":
+		return parseURLLink(link, PROTOCOL_TROJAN)
+	case "vmess":
+		return parseVMess(link)
+	case "ss":
+		return parseShadowsocks(link)
+	default:
+		return Outbound{}, fmt.Errorf("%w: scheme %s", ErrUnsupportedLink, scheme)
+	}
+}
+
+// ParseAll parses every link and names outbounds uniquely,
+// as client configs refer to outbounds by tag.
+func ParseAll(links []string) ([]Outbound, error) {
+	outbounds := make([]Outbound, 0, len(links))
+	tags := make(map[string]int, len(links))
+	for _, link := range links {
+		outbound, err := Parse(link)
+		if err != nil {
+			return nil, err
+		}
+		if outbound.Tag == "" {
+			outbound.Tag = outbound.Protocol
+		}
+		tags[outbound.Tag]++
+		if n := tags[outbound.Tag]; n > 1 {
+			outbound.Tag = fmt.Sprintf("%s %d", outbound.Tag, n)
+		}
+		outbounds = append(outbounds, outbound)
+	}
+	return outbounds, nil
+}
+
+func parseURLLink(link, protocol string) (Outbound, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return Outbound{}, fmt.Errorf("parsing %s link: %w", protocol, err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return Outbound{}, fmt.Errorf("parsing %s link port: %w", protocol, err)
+	}
+	q := u.Query()
+
+	outbound := Outbound{
+		Tag:         u.Fragment,
+		Protocol:    protocol,
+		Server:      u.Hostname(),
+		Port:        port,
+		Flow:        q.Get("flow"),
+		Security:    q.Get("security"),
+		SNI:         q.Get("sni"),
+		Fingerprint: q.Get("fp"),
+		PublicKey:   q.Get("pbk"),
+		ShortID:     q.Get("sid"),
+		Network:     q.Get("type"),
+		Path:        q.Get("path"),
+		Host:        q.Get("host"),
+		ServiceName: q.Get("serviceName"),
+	}
+	if alpn := q.Get("alpn"); alpn != "" {
+		outbound.ALPN = strings.Split(alpn, ",")
+	}
+	if protocol == PROTOCOL_VLESS {
+		outbound.UUID = u.User.Username()
+	} else {
+		outbound.Password = u.User.Username()
+	}
+	if outbound.Security == "" {
+		outbound.Security = SECURITY_NONE
+		if protocol == PROTOCOL_TROJAN {
+			outbound.Security = SECURITY_TLS
+		}
+	}
+	if outbound.Network == "" {
+		outbound.Network = "tcp"
+	}
+	return outbound, nil
+}
+
+// vmessLink is the JSON inside a vmess:// link.
+type vmessLink struct {
+	Ps   string          `json:"ps"`
+	Add  string          `json:"add"`
+	Port json.RawMessage `json:"port"`
+	ID   string          `json:"id"`
+	Aid  json.RawMessage `json:"aid"`
+	Scy  string          `json:"scy"`
+	Net  string          `json:"net"`
+	Host string          `json:"host"`
+	Path string          `json:"path"`
+	TLS  string          `json:"tls"`
+	SNI  string          `json:"sni"`
+	ALPN string          `json:"alpn"`
+	FP   string          `json:"fp"`
+}
+
+func parseVMess(link string) (Outbound, error) {
+	payload, err := decodeBase64(strings.TrimPrefix(link, "vmess://"))
+	if err != nil {
+		return Outbound{}, fmt.Errorf("decoding vmess link: %w", err)
+	}
+	var v vmessLink
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return Outbound{}, fmt.Errorf("decoding vmess link: %w", err)
+	}
+	port, err := jsonInt(v.Port)
+	if err != nil {
+		return Outbound{}, fmt.Errorf("parsing vmess link port: %w", err)
+	}
+	alterID, _ := jsonInt(v.Aid)
+
+	outbound := Outbound{
+		Tag:         v.Ps,
+		Protocol:    PROTOCOL_VMESS,
+		Server:      v.Add,
+		Port:        port,
+		UUID:        v.ID,
+		Cipher:      v.Scy,
+		AlterID:     alterID,
+		Security:    SECURITY_NONE,
+		SNI:         v.SNI,
+		Fingerprint: v.FP,
+		Network:     v.Net,
+		Host:        v.Host,
+		Path:        v.Path,
+	}
+	if v.TLS == SECURITY_TLS {
+		outbound.Security = SECURITY_TLS
+	}
+	if v.ALPN != "" {
+		outbound.ALPN = strings.Split(v.ALPN, ",")
+	}
+	if outbound.Cipher == "" {
+		outbound.Cipher = "auto"
+	}
+	if outbound.Network == "" {
+		outbound.Network = "tcp"
+	}
+	if outbound.Network == "grpc" {
+		outbound.ServiceName = v.Path
+	}
+	return outbound, nil
+}
+
+// parseShadowsocks supports both the SIP002 form, where only the user info
+// is encoded, and the legacy form with the whole link in base64.
+func parseShadowsocks(link string) (Outbound, error) {
+	body, tag, _ := strings.Cut(strings.TrimPrefix(link, "ss://"), "#")
+	tag, _ = url.PathUnescape(tag)
+	body, _, _ = strings.Cut(body, "?")
+
+	userInfo, hostPort, ok := strings.Cut(body, "@")
+	if !ok {
+		decoded, err := decodeBase64(body)
+		if err != nil {
+			return Outbound{}, fmt.Errorf("decoding ss link: %w", err)
+		}
+		userInfo, hostPort, ok = strings.Cut(string(decoded), "@")
+		if !ok {
+			return Outbound{}, fmt.Errorf("%w: ss link without server", ErrUnsupportedLink)
+		}
+	} else if decoded, err := decodeBase64(userInfo); err == nil {
+		userInfo = string(decoded)
+	} else if unescaped, err := url.PathUnescape(userInfo); err == nil {
+		userInfo = unescaped
+	}
+
+	method, password, ok := strings.Cut(userInfo, ":")
+	if !ok {
+		return Outbound{}, fmt.Errorf("%w: ss link without method", ErrUnsupportedLink)
+	}
+	host, portValue, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return Outbound{}, fmt.Errorf("parsing ss link server: %w", err)
+	}
+	port, err := strconv.Atoi(portValue)
+	if err != nil {
+		return Outbound{}, fmt.Errorf("parsing ss link port: %w", err)
+	}
+
+	return Outbound{
+		Tag:      tag,
+		Protocol: PROTOCOL_SHADOWSOCKS,
+		Server:   host,
+		Port:     port,
+		Method:   method,
+		Password: password,
+		Security: SECURITY_NONE,
+		Network:  "tcp",
+	}, nil
+}
+
+func decodeBase64(value string) ([]byte, error) {
+	value = strings.TrimRight(strings.TrimSpace(value), "=")
+	if decoded, err := base64.RawStdEncoding.DecodeString(value); err == nil {
+		return decoded, nil
+	}
+	return base64.RawURLEncoding.DecodeString(value)
+}
+
+// jsonInt reads numbers that some panels put into vmess links as strings.
+func jsonInt(raw json.RawMessage) (int, error) {
+	if len(raw) == 0 {
+		return 0, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return strconv.Atoi(s)
+	}
+	var n int
+	err := json.Unmarshal(raw, &n)
+	return n, err
+}
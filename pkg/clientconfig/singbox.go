@@ -0,0 +1,112 @@
+package clientconfig
+
+import (
+	"encoding/json"
+)
+
+// SingBox builds a sing-box config with a selector over the outbounds
+// and a local mixed inbound.
+func SingBox(outbounds []Outbound) ([]byte, error) {
+	tags := make([]string, 0, len(outbounds))
+	boxOutbounds := make([]map[string]any, 0, len(outbounds)+2)
+	for _, o := range outbounds {
+		tags = append(tags, o.Tag)
+		boxOutbounds = append(boxOutbounds, singBoxOutbound(o))
+	}
+	boxOutbounds = append([]map[string]any{{
+		"type":      "selector",
+		"tag":       "proxy",
+		"outbounds": tags,
+	}}, boxOutbounds...)
+	boxOutbounds = append(boxOutbounds, map[string]any{"type": "direct", "tag": "direct"})
+
+	config := map[string]any{
+		"log": map[string]any{"level": "warn"},
+		"inbounds": []map[string]any{{
+			"type":        "mixed",
+			"tag":         "mixed-in",
+			"listen":      "127.0.0.1",
+			"listen_port": 2080,
+		}},
+		"outbounds": boxOutbounds,
+		"route": map[string]any{
+			"final":                 "proxy",
+			"auto_detect_interface": true,
+		},
+	}
+	return json.MarshalIndent(config, "", "  ")
+}
+
+func singBoxOutbound(o Outbound) map[string]any {
+	outbound := map[string]any{
+		"type":        o.Protocol,
+		"tag":         o.Tag,
+		"server":      o.Server,
+		"server_port": o.Port,
+	}
+
+	switch o.Protocol {
+	case PROTOCOL_VLESS:
+		outbound["uuid"] = o.UUID
+		if o.Flow != "" {
+			outbound["flow"] = o.Flow
+		}
+	case PROTOCOL_VMESS:
+		outbound["uuid"] = o.UUID
+		outbound["security"] = o.Cipher
+		outbound["alter_id"] = o.AlterID
+	case PROTOCOL_TROJAN:
+		outbound["password"] = o.Password
+	case PROTOCOL_SHADOWSOCKS:
+		outbound["method"] = o.Method
+		outbound["password"] = o.Password
+	}
+
+	if o.Security == SECURITY_TLS || o.Security == SECURITY_REALITY {
+		tls := map[string]any{
+			"enabled":     true,
+			"server_name": firstNonEmpty(o.SNI, o.Host, o.Server),
+		}
+		if len(o.ALPN) > 0 {
+			tls["alpn"] = o.ALPN
+		}
+		if o.Fingerprint != "" {
+			tls["utls"] = map[string]any{"enabled": true, "fingerprint": o.Fingerprint}
+		}
+		if o.Security == SECURITY_REALITY {
+			tls["reality"] = map[string]any{
+				"enabled":    true,
+				"public_key": o.PublicKey,
+				"short_id":   o.ShortID,
+			}
+		}
+		outbound["tls"] = tls
+	}
+
+	switch o.Network {
+	case "ws":
+		transport := map[string]any{"type": "ws", "path": o.Path}
+		if o.Host != "" {
+			transport["headers"] = map[string]any{"Host": o.Host}
+		}
+		outbound["transport"] = transport
+	case "grpc":
+		outbound["transport"] = map[string]any{"type": "grpc", "service_name": o.ServiceName}
+	case "http", "h2":
+		transport := map[string]any{"type": "http", "path": o.Path}
+		if o.Host != "" {
+			transport["host"] = []string{o.Host}
+		}
+		outbound["transport"] = transport
+	}
+	return outbound
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
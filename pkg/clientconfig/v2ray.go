@@ -0,0 +1,102 @@
+package clientconfig
+
+import (
+	"encoding/json"
+)
+
+// V2Ray builds a v2ray/Xray config. The clients use the first outbound
+// by default, the rest are kept for switching by tag.
+func V2Ray(outbounds []Outbound) ([]byte, error) {
+	rayOutbounds := make([]map[string]any, 0, len(outbounds)+1)
+	for _, o := range outbounds {
+		rayOutbounds = append(rayOutbounds, v2rayOutbound(o))
+	}
+	rayOutbounds = append(rayOutbounds, map[string]any{"protocol": "freedom", "tag": "direct"})
+
+	config := map[string]any{
+		"log": map[string]any{"loglevel": "warning"},
+		"inbounds": []map[string]any{
+			{"tag": "socks", "listen": "127.0.0.1", "port": 10808, "protocol": "socks", "settings": map[string]any{"udp": true}},
+			{"tag": "http", "listen": "127.0.0.1", "port": 10809, "protocol": "http"},
+		},
+		"outbounds": rayOutbounds,
+	}
+	return json.MarshalIndent(config, "", "  ")
+}
+
+func v2rayOutbound(o Outbound) map[string]any {
+	outbound := map[string]any{
+		"tag":      o.Tag,
+		"protocol": o.Protocol,
+	}
+
+	switch o.Protocol {
+	case PROTOCOL_VLESS:
+		user := map[string]any{"id": o.UUID, "encryption": "none"}
+		if o.Flow != "" {
+			user["flow"] = o.Flow
+		}
+		outbound["settings"] = map[string]any{"vnext": []map[string]any{{
+			"address": o.Server, "port": o.Port, "users": []map[string]any{user},
+		}}}
+	case PROTOCOL_VMESS:
+		outbound["settings"] = map[string]any{"vnext": []map[string]any{{
+			"address": o.Server, "port": o.Port, "users": []map[string]any{{
+				"id": o.UUID, "alterId": o.AlterID, "security": o.Cipher,
+			}},
+		}}}
+	case PROTOCOL_TROJAN:
+		outbound["settings"] = map[string]any{"servers": []map[string]any{{
+			"address": o.Server, "port": o.Port, "password": o.Password,
+		}}}
+	case PROTOCOL_SHADOWSOCKS:
+		outbound["settings"] = map[string]any{"servers": []map[string]any{{
+			"address": o.Server, "port": o.Port, "method": o.Method, "password": o.Password,
+		}}}
+	}
+
+	stream := map[string]any{
+		"network":  o.Network,
+		"security": o.Security,
+	}
+	if o.Network == "http" || o.Network == "h2" {
+		stream["network"] = "h2"
+	}
+	serverName := firstNonEmpty(o.SNI, o.Host, o.Server)
+	switch o.Security {
+	case SECURITY_TLS:
+		tls := map[string]any{"serverName": serverName}
+		if len(o.ALPN) > 0 {
+			tls["alpn"] = o.ALPN
+		}
+		if o.Fingerprint != "" {
+			tls["fingerprint"] = o.Fingerprint
+		}
+		stream["tlsSettings"] = tls
+	case SECURITY_REALITY:
+		stream["realitySettings"] = map[string]any{
+			"serverName":  serverName,
+			"fingerprint": firstNonEmpty(o.Fingerprint, "chrome"),
+			"publicKey":   o.PublicKey,
+			"shortId":     o.ShortID,
+		}
+	}
+	switch o.Network {
+	case "ws":
+		ws := map[string]any{"path": o.Path}
+		if o.Host != "" {
+			ws["headers"] = map[string]any{"Host": o.Host}
+		}
+		stream["wsSettings"] = ws
+	case "grpc":
+		stream["grpcSettings"] = map[string]any{"serviceName": o.ServiceName}
+	case "http", "h2":
+		h2 := map[string]any{"path": o.Path}
+		if o.Host != "" {
+			h2["host"] = []string{o.Host}
+		}
+		stream["httpSettings"] = h2
+	}
+	outbound["streamSettings"] = stream
+	return outbound
+}
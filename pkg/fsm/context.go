@@ -2,6 +2,7 @@ package fsm
 
 type FSMContext struct {
 	State State
+	Event Event
 	Input any
 	Data  map[string]any
 	Meta  map[string]any
@@ -10,6 +11,7 @@ type FSMContext struct {
 func newFSMContext(initial State) *FSMContext {
 	return &FSMContext{
 		State: initial,
+		Event: "",
 		Input: nil,
 		Data:  make(map[string]any),
 		Meta:  make(map[string]any),
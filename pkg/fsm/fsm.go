@@ -91,6 +91,7 @@ func (fsm *FSM) Trigger(event Event, input ...any) error {
 	fsm.mu.Lock()
 	defer fsm.mu.Unlock()
 
+	fsm.ctx.Event = event
 	if len(input) > 0 {
 		fsm.ctx.Input = input[0]
 	} else {
@@ -163,6 +164,7 @@ func (fsm *FSM) Trigger(event Event, input ...any) error {
 
 func (fsm *FSM) CallEnter(state State) error {
 	fsm.ctx.State = state
+	fsm.ctx.Event = ""
 
 	if onEnterCallbackList, ok := fsm.onEnter[state]; ok {
 		for _, cb := range onEnterCallbackList {
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// JSONFile keeps a single value of type T in a JSON file on disk.
+// Writes go through a temporary file and a rename, so a crash
+// never leaves a half-written file behind.
+type JSONFile[T any] struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewJSONFile[T any](path string) *JSONFile[T] {
+	return &JSONFile[T]{
+		path: path,
+	}
+}
+
+func (f *JSONFile[T]) Path() string {
+	return f.path
+}
+
+// Load reads the stored value. The returned flag is false
+// when the file does not exist yet.
+func (f *JSONFile[T]) Load() (T, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var value T
+	raw, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return value, false, nil
+	}
+	if err != nil {
+		return value, false, fmt.Errorf("could not read %s: %w", f.path, err)
+	}
+
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return value, false, fmt.Errorf("could not decode %s: %w", f.path, err)
+	}
+	return value, true, nil
+}
+
+func (f *JSONFile[T]) Save(value T) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	raw, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode %s: %w", f.path, err)
+	}
+
+	dir := filepath.Dir(f.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("could not create %s: %w", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("could not write %s: %w", f.path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write %s: %w", f.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write %s: %w", f.path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("could not write %s: %w", f.path, err)
+	}
+	return nil
+}